@@ -15,10 +15,14 @@
 package cmd
 
 import (
+	"path/filepath"
+	"time"
+
 	"github.com/helm/chart-releaser/pkg/config"
 	"github.com/helm/chart-releaser/pkg/git"
-	"github.com/helm/chart-releaser/pkg/github"
 	"github.com/helm/chart-releaser/pkg/releaser"
+	"github.com/helm/chart-releaser/pkg/version"
+	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 )
 
@@ -35,9 +39,18 @@ given GitHub repository's releases.
 		if err != nil {
 			return err
 		}
-		ghc := github.NewClient(config.Owner, config.GitRepo, config.Token, config.GitBaseURL, config.GitUploadURL)
-		releaser := releaser.NewReleaser(config, ghc, &git.Git{})
-		_, err = releaser.UpdateIndexFile()
+		releaser, err := releaser.NewReleaser(config, &git.Git{})
+		if err != nil {
+			return err
+		}
+		defer releaser.Cleanup() // nolint, errcheck
+		ctx, cancel := newContext(config.Timeout)
+		defer cancel()
+		if config.PublishDrafts {
+			_, err = releaser.Publish(ctx)
+		} else {
+			_, err = releaser.UpdateIndexFile(ctx)
+		}
 		return err
 	},
 }
@@ -48,18 +61,80 @@ func getRequiredIndexArgs() []string {
 
 func init() {
 	rootCmd.AddCommand(indexCmd)
+	dir, err := homedir.Dir()
+	if err != nil {
+		panic(err)
+	}
+
 	flags := indexCmd.Flags()
 	flags.StringP("owner", "o", "", "GitHub username or organization")
 	flags.StringP("git-repo", "r", "", "GitHub repository")
 	flags.StringP("charts-repo", "c", "", "The URL to the charts repository")
+	flags.String("remote-index-url", "", "URL of the existing index.yaml (defaults to index.yaml in the charts repository)")
 	flags.StringP("index-path", "i", ".cr-index/index.yaml", "Path to index file")
-	flags.StringP("package-path", "p", ".cr-release-packages", "Path to directory with chart packages")
+	flags.StringP("package-path", "p", ".cr-release-packages", "Path to directory with chart packages, multiple directories may be separated by commas")
+	flags.StringSlice("skip-charts", nil, "Glob patterns of chart names whose packages are skipped, e.g. '*-dev'")
+	flags.Bool("skip-deprecated", false, "Skip packages of charts marked as deprecated in Chart.yaml, which cr upload does not release with this option")
 	flags.StringP("token", "t", "", "GitHub Auth Token (only needed for private repos)")
-	flags.StringP("git-base-url", "b", "https://api.github.com/", "GitHub Base URL (only needed for private GitHub)")
-	flags.StringP("git-upload-url", "u", "https://uploads.github.com/", "GitHub Upload URL (only needed for private GitHub)")
+	flags.String("token-file", "", "File the GitHub Auth Token is read from, if --token is not set")
+	flags.String("token-command", "", "Command printing the GitHub Auth Token, e.g. a credential helper, if neither --token nor --token-file is set")
+	flags.Int("max-retries", 3, "Maximum number of retries for failed GitHub API calls")
+	flags.Duration("retry-delay", time.Second, "Base delay between retries of failed GitHub API calls, growing exponentially")
+	flags.Bool("rate-limit-pause", false, "Pause and resume when hitting GitHub's secondary rate limits instead of failing")
+	flags.String("user-agent", version.UserAgent(), "User-Agent header of the requests to GitHub and of the downloads of the index and chart packages")
+	flags.StringSlice("extra-headers", nil, "Extra headers of the requests to GitHub and of the downloads of the index and chart packages, e.g. 'X-Proxy-Token: secret'")
+	flags.String("proxy", "", "URL of the proxy for the requests to GitHub and the downloads of the index and chart packages (defaults to HTTPS_PROXY or HTTP_PROXY)")
+	flags.StringSlice("no-proxy", nil, "Hosts, domains and IP ranges which are not reached through the proxy, e.g. '.example.com' (defaults to NO_PROXY)")
+	flags.String("provider", "github", "The Git hosting provider the releases are read from (github, gitlab, gitea)")
+	flags.StringP("git-base-url", "b", "https://api.github.com/", "GitHub Base URL (only needed for private GitHub, defaults to https://gitlab.com/api/v4/ for GitLab, required for Gitea)")
+	flags.StringP("git-upload-url", "u", "", "GitHub Upload URL (only needed for private GitHub, defaults to https://uploads.github.com/ or the api/uploads/ endpoint of the GitHub Enterprise host)")
 	flags.String("pages-branch", "gh-pages", "The GitHub pages branch")
+	flags.String("pages-index-path", "index.yaml", "Path of index.yaml in the GitHub Pages branch")
+	flags.String("url-template", "", "Go template for computing the URLs of the chart packages in the index, using chart metadata and the .Filename and .URL of the chart package, e.g. 'https://cdn.example.com/{{ .Name }}/{{ .Filename }}' (defaults to the .URL)")
+	flags.StringSlice("mirror-base-urls", nil, "Base URLs of mirrors serving the chart packages as well, whose URLs are listed after the primary URL of each chart version added to the index, e.g. https://mirror.example.com/charts (can be specified multiple times)")
+	flags.StringToString("chart-aliases", nil, "Published names of charts keyed by chart name, used for the release names, tags, asset names and index entries of the chart, e.g. internal-foo=foo")
+	flags.String("unstable-index-path", "", "Path to a separate index file for chart versions with a SemVer prerelease component, which are kept out of index-path then")
+	flags.String("unstable-pages-index-path", "unstable/index.yaml", "Path of the unstable index.yaml in the GitHub Pages branch")
+	flags.StringToString("index-routing", nil, "Paths of the index.yaml of sub-repositories in the GitHub Pages branch keyed by chart name prefix, which index the charts whose name starts with the prefix instead of the main index, e.g. team-a-=team-a/index.yaml")
+	flags.String("commit-message-template", "", "Go template for computing the message of the index commit, using the .Charts added to the index (defaults to \"Update index.yaml\")")
+	flags.Bool("packages-with-index", false, "Commit the chart packages next to index.yaml in the GitHub Pages branch instead of linking to GitHub Releases")
+	flags.String("base-url", "", "URL the chart packages committed with packages-with-index are served from, e.g. https://org.github.io/repo or https://charts.example.com (defaults to the charts repository)")
+	flags.StringSlice("template-env", nil, "Environment variables the env and expandenv template functions may read, e.g. PAGES_DOMAIN (can be specified multiple times, defaults to all)")
+	flags.Bool("write-manifest", false, "Write the checksum of index.yaml to index.yaml.sha256 and a manifest of all chart versions to manifest.json next to it")
+	flags.Bool("gzip-index", false, "Write a gzipped copy of index.yaml to index.yaml.gz next to it, for clients supporting compressed indexes")
+	flags.Bool("generate-html", false, "Render a landing page listing the charts and their versions to index.html next to index.yaml")
+	flags.String("html-template", "", "Path of a Go html/template for the landing page, which gets the .Charts, the .RepoURL and the time the index was .Generated (defaults to a plain list)")
+	flags.String("generated-timestamp", "", "Unix time in seconds or RFC 3339 time index.yaml is marked as generated at, for reproducible indexes (defaults to SOURCE_DATE_EPOCH if set, or the current time)")
+	flags.Bool("sign-index", false, "Use a PGP private key to write a detached signature of index.yaml to index.yaml.asc next to it")
+	flags.String("key", "", "Name of the key to use when signing")
+	flags.String("keyring", filepath.Join(dir, ".gnupg", "pubring.gpg"), "Location of a public keyring")
+	flags.String("passphrase-file", "", "Location of a file which contains the passphrase for the signing key. Use '-' in order to read from stdin")
+	flags.Bool("stable-generated", false, "Only write index.yaml if its entries changed, ignoring the time it was generated")
+	flags.Bool("rebuild-index", false, "Rebuild index.yaml from the chart packages in the package path, removing the entries of the existing index.yaml whose packages are not in it")
+	flags.StringSlice("only-charts", nil, "Names of the charts whose new versions are merged into the existing index.yaml, leaving the entries of all other charts untouched (defaults to all charts)")
+	flags.Bool("fail-on-index-shrink", false, "Fail instead of writing index.yaml if it would contain fewer chart versions than the existing index")
+	flags.Bool("allow-index-shrink", false, "Write index.yaml even if it shrinks while fail-on-index-shrink is set, e.g. for a legitimate prune")
 	flags.String("remote", "origin", "The Git remote used when creating a local worktree for the GitHub Pages branch")
+	flags.String("git-push-mode", "https", "How the GitHub Pages branch is pushed: https authenticates with the token, ssh uses the SSH form of the remote URL and the SSH keys of the environment (https, ssh)")
+	flags.String("worktree-dir", "", "Directory the worktree of the GitHub Pages branch is created in, e.g. $RUNNER_TEMP (defaults to the directory for temporary files)")
+	flags.String("git-user-name", "", "Name of the author and committer of index commits (defaults to user.name of the Git configuration or chart-releaser[bot])")
+	flags.String("git-user-email", "", "Email of the author and committer of index commits (defaults to user.email of the Git configuration or the one of chart-releaser[bot])")
+	flags.Bool("sign-commits", false, "GPG-sign index commits, failing if signing is not possible")
+	flags.String("commit-signing-key", "", "ID of the GPG key index commits are signed with (defaults to the key matching the committer identity)")
 	flags.Bool("push", false, "Push index.yaml to the GitHub Pages branch (must not be set if --pr is set)")
 	flags.Bool("pr", false, "Create a pull request for index.yaml against the GitHub Pages branch (must not be set if --push is set)")
-	flags.String("release-name-template", "{{ .Name }}-{{ .Version }}", "Go template for computing release names, using chart metadata")
+	flags.String("storage-backend", "", "Storage backend the chart packages and index.yaml are published to instead of GitHub Releases (s3, gcs)")
+	flags.String("storage-bucket", "", "Bucket of the storage backend")
+	flags.String("storage-prefix", "", "Prefix of the objects in the storage backend bucket")
+	flags.String("s3-region", "", "AWS region of the S3 bucket (defaults to the region of the AWS configuration)")
+	flags.StringSlice("index-mirrors", nil, "Storage URLs index.yaml is copied to after it has been written, e.g. s3://bucket/prefix or gcs://bucket/prefix (can be specified multiple times)")
+	flags.Bool("mirror-packages", false, "Copy the chart packages added to the index to the index mirrors as well")
+	flags.Bool("publish-drafts", false, "Publish the draft releases of the chart packages before adding them to the index")
+	flags.String("release-name-template", "", "Go template for computing release names, using chart metadata and the .Path of the chart package (defaults to the release tag template)")
+	flags.String("release-tag-template", "", "Go template for computing release tags, using chart metadata and the .Path of the chart package (defaults to the release name template or \"{{ .Name }}-{{ .Version }}\")")
+	flags.String("asset-name-template", "", "Go template for computing the file names chart packages are uploaded as, using chart metadata and the .Path of the chart package, e.g. \"{{ .Name }}_{{ .Version }}.tgz\" (defaults to the file name of the package)")
+	flags.Bool("dry-run", false, "Print the actions that would be taken instead of updating the index")
+	flags.Bool("skip-index", false, "Only create the releases and upload their assets, leaving index.yaml to another tool: the index is neither downloaded by cr upload nor updated by cr index")
+	flags.String("log-format", "text", "Log output format (text, json)")
+	flags.Duration("timeout", 0, "Maximum duration of the command, e.g. 10m (no limit by default)")
 }
@@ -62,6 +62,10 @@ func init() {
 
 	rootCmd.AddCommand(packageCmd)
 	packageCmd.Flags().StringP("package-path", "p", ".cr-release-packages", "Path to directory with chart packages")
+	packageCmd.Flags().Bool("package-with-dependency-update", true, "Update chart dependencies before packaging")
+	packageCmd.Flags().StringSlice("dependency-repos", nil, "Helm repositories the chart dependencies are resolved from as name=url pairs, e.g. bitnami=https://charts.bitnami.com/bitnami, without running 'helm repo add' first")
+	packageCmd.Flags().StringSlice("package-values", nil, "Values files whose values override the default values in values.yaml of the packaged charts, e.g. for per-environment releases")
+	packageCmd.Flags().StringSlice("package-set", nil, "Values overriding the default values in values.yaml of the packaged charts, as key=value pairs like helm's --set")
 	packageCmd.Flags().Bool("sign", false, "Use a PGP private key to sign this package")
 	packageCmd.Flags().String("key", "", "Name of the key to use when signing")
 	packageCmd.Flags().String("keyring", filepath.Join(dir, ".gnupg", "pubring.gpg"), "Location of a public keyring")
@@ -0,0 +1,110 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/git"
+	"github.com/helm/chart-releaser/pkg/releaser"
+	"github.com/helm/chart-releaser/pkg/version"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete releases of old chart versions",
+	Long: `
+Delete the GitHub releases of chart versions which are not retained by
+the given retention policy, which is applied to every chart separately.
+Optionally the deleted versions are removed from the index.yaml as well.
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := config.LoadConfiguration(cfgFile, cmd, getRequiredPruneArgs())
+		if err != nil {
+			return err
+		}
+		releaser, err := releaser.NewReleaser(config, &git.Git{})
+		if err != nil {
+			return err
+		}
+		defer releaser.Cleanup() // nolint, errcheck
+		ctx, cancel := newContext(config.Timeout)
+		defer cancel()
+		return releaser.Prune(ctx)
+	},
+}
+
+func getRequiredPruneArgs() []string {
+	return []string{"owner", "git-repo", "token"}
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	dir, err := homedir.Dir()
+	if err != nil {
+		panic(err)
+	}
+
+	flags := pruneCmd.Flags()
+	flags.StringP("owner", "o", "", "GitHub username or organization")
+	flags.StringP("git-repo", "r", "", "GitHub repository")
+	flags.StringP("token", "t", "", "GitHub Auth Token")
+	flags.String("token-file", "", "File the GitHub Auth Token is read from, if --token is not set")
+	flags.String("token-command", "", "Command printing the GitHub Auth Token, e.g. a credential helper, if neither --token nor --token-file is set")
+	flags.Int("max-retries", 3, "Maximum number of retries for failed GitHub API calls")
+	flags.Duration("retry-delay", time.Second, "Base delay between retries of failed GitHub API calls, growing exponentially")
+	flags.Bool("rate-limit-pause", false, "Pause and resume when hitting GitHub's secondary rate limits instead of failing")
+	flags.String("user-agent", version.UserAgent(), "User-Agent header of the requests to GitHub and of the downloads of the index and chart packages")
+	flags.StringSlice("extra-headers", nil, "Extra headers of the requests to GitHub and of the downloads of the index and chart packages, e.g. 'X-Proxy-Token: secret'")
+	flags.String("proxy", "", "URL of the proxy for the requests to GitHub and the downloads of the index and chart packages (defaults to HTTPS_PROXY or HTTP_PROXY)")
+	flags.StringSlice("no-proxy", nil, "Hosts, domains and IP ranges which are not reached through the proxy, e.g. '.example.com' (defaults to NO_PROXY)")
+	flags.String("provider", "github", "The Git hosting provider the releases are deleted from (github, gitlab, gitea)")
+	flags.StringP("git-base-url", "b", "https://api.github.com/", "GitHub Base URL (only needed for private GitHub, defaults to https://gitlab.com/api/v4/ for GitLab, required for Gitea)")
+	flags.StringP("git-upload-url", "u", "", "GitHub Upload URL (only needed for private GitHub, defaults to https://uploads.github.com/ or the api/uploads/ endpoint of the GitHub Enterprise host)")
+	flags.Int("prune-keep-last", 0, "Number of the latest versions of each chart to keep")
+	flags.Duration("prune-max-age", 0, "Keep versions whose release is younger than this age, in addition to the last versions (e.g. 2160h)")
+	flags.Bool("prune-index", false, "Remove the deleted versions from index.yaml of the charts repository")
+	flags.StringP("charts-repo", "c", "", "The URL to the charts repository")
+	flags.String("remote-index-url", "", "URL of the existing index.yaml (defaults to index.yaml in the charts repository)")
+	flags.StringP("index-path", "i", ".cr-index/index.yaml", "Path to index file")
+	flags.String("pages-branch", "gh-pages", "The GitHub pages branch")
+	flags.String("pages-index-path", "index.yaml", "Path of index.yaml in the GitHub Pages branch")
+	flags.Bool("write-manifest", false, "Write the checksum of index.yaml to index.yaml.sha256 and a manifest of all chart versions to manifest.json next to it")
+	flags.Bool("gzip-index", false, "Write a gzipped copy of index.yaml to index.yaml.gz next to it, for clients supporting compressed indexes")
+	flags.Bool("generate-html", false, "Render a landing page listing the charts and their versions to index.html next to index.yaml")
+	flags.String("html-template", "", "Path of a Go html/template for the landing page, which gets the .Charts, the .RepoURL and the time the index was .Generated (defaults to a plain list)")
+	flags.String("generated-timestamp", "", "Unix time in seconds or RFC 3339 time index.yaml is marked as generated at, for reproducible indexes (defaults to SOURCE_DATE_EPOCH if set, or the current time)")
+	flags.Bool("sign-index", false, "Use a PGP private key to write a detached signature of index.yaml to index.yaml.asc next to it")
+	flags.String("key", "", "Name of the key to use when signing")
+	flags.String("keyring", filepath.Join(dir, ".gnupg", "pubring.gpg"), "Location of a public keyring")
+	flags.String("passphrase-file", "", "Location of a file which contains the passphrase for the signing key. Use '-' in order to read from stdin")
+	flags.String("remote", "origin", "The Git remote used when creating a local worktree for the GitHub Pages branch")
+	flags.String("git-push-mode", "https", "How the GitHub Pages branch is pushed: https authenticates with the token, ssh uses the SSH form of the remote URL and the SSH keys of the environment (https, ssh)")
+	flags.String("worktree-dir", "", "Directory the worktree of the GitHub Pages branch is created in, e.g. $RUNNER_TEMP (defaults to the directory for temporary files)")
+	flags.String("git-user-name", "", "Name of the author and committer of index commits (defaults to user.name of the Git configuration or chart-releaser[bot])")
+	flags.String("git-user-email", "", "Email of the author and committer of index commits (defaults to user.email of the Git configuration or the one of chart-releaser[bot])")
+	flags.Bool("sign-commits", false, "GPG-sign index commits, failing if signing is not possible")
+	flags.String("commit-signing-key", "", "ID of the GPG key index commits are signed with (defaults to the key matching the committer identity)")
+	flags.Bool("push", false, "Push index.yaml to the GitHub Pages branch (must not be set if --pr is set)")
+	flags.Bool("pr", false, "Create a pull request for index.yaml against the GitHub Pages branch (must not be set if --push is set)")
+	flags.Bool("dry-run", false, "Print the releases and index entries that would be deleted instead of deleting them")
+	flags.String("log-format", "text", "Log output format (text, json)")
+	flags.Duration("timeout", 0, "Maximum duration of the command, e.g. 10m (no limit by default)")
+}
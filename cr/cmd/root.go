@@ -15,7 +15,9 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -37,6 +39,15 @@ func Execute() {
 	}
 }
 
+// newContext returns the context for running a command, which is cancelled
+// once the timeout has passed if it is set.
+func newContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default is $HOME/.cr.yaml)")
 }
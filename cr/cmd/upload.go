@@ -15,10 +15,14 @@
 package cmd
 
 import (
+	"path/filepath"
+	"time"
+
 	"github.com/helm/chart-releaser/pkg/config"
 	"github.com/helm/chart-releaser/pkg/git"
-	"github.com/helm/chart-releaser/pkg/github"
 	"github.com/helm/chart-releaser/pkg/releaser"
+	"github.com/helm/chart-releaser/pkg/version"
+	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 )
 
@@ -32,9 +36,24 @@ var uploadCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		ghc := github.NewClient(config.Owner, config.GitRepo, config.Token, config.GitBaseURL, config.GitUploadURL)
-		releaser := releaser.NewReleaser(config, ghc, &git.Git{})
-		return releaser.CreateReleases()
+		releaser, err := releaser.NewReleaser(config, &git.Git{})
+		if err != nil {
+			return err
+		}
+		ctx, cancel := newContext(config.Timeout)
+		defer cancel()
+		if config.SourceRepo != "" {
+			return releaser.ReleaseFromSource(ctx)
+		}
+		if config.Since != "" {
+			return releaser.ReleaseChanged(ctx)
+		}
+		if config.ChartsDir != "" {
+			if err := releaser.Package(); err != nil {
+				return err
+			}
+		}
+		return releaser.CreateReleases(ctx)
 	},
 }
 
@@ -43,14 +62,77 @@ func getRequiredUploadArgs() []string {
 }
 
 func init() {
+	dir, err := homedir.Dir()
+	if err != nil {
+		panic(err)
+	}
+
 	rootCmd.AddCommand(uploadCmd)
 	uploadCmd.Flags().StringP("owner", "o", "", "GitHub username or organization")
 	uploadCmd.Flags().StringP("git-repo", "r", "", "GitHub repository")
-	uploadCmd.Flags().StringP("package-path", "p", ".cr-release-packages", "Path to directory with chart packages")
+	uploadCmd.Flags().StringP("package-path", "p", ".cr-release-packages", "Path to directory with chart packages, multiple directories may be separated by commas")
+	uploadCmd.Flags().StringSlice("skip-charts", nil, "Glob patterns of chart names whose packages are skipped, e.g. '*-dev'")
+	uploadCmd.Flags().Bool("skip-deprecated", false, "Skip packages of charts marked as deprecated in Chart.yaml instead of releasing them")
+	uploadCmd.Flags().Bool("allow-empty", false, "Succeed without releasing anything if the package path contains no chart packages, instead of failing")
+	uploadCmd.Flags().Bool("skip-index", false, "Only create the releases and upload their assets, leaving index.yaml to another tool: the index is neither downloaded by cr upload nor updated by cr index")
+	uploadCmd.Flags().String("state-file", "", "File recording the chart versions released so far, so that an interrupted run can be resumed by running it again with the same state file (removed once all releases succeeded)")
+	uploadCmd.Flags().Bool("validate-values-schema", false, "Validate the default values of every chart against its values.schema.json before releasing and fail on violations")
+	uploadCmd.Flags().String("pre-release-hook", "", "Go template for computing a shell command which is run for every chart package before releasing and aborts the upload if it fails, using chart metadata and the .Package path, e.g. \"helm lint {{ .Package }}\"")
+	uploadCmd.Flags().Bool("verify-provenance", false, "Verify the provenance files of the chart packages against the keyring before releasing and fail if any does not verify")
+	uploadCmd.Flags().String("charts-dir", "", "Directory with charts which are packaged into the package path before uploading")
+	uploadCmd.Flags().String("source-repo", "", "URL of a Git repository which is cloned to package and upload the charts in its charts-dir, or in its charts directory if charts-dir is not set")
+	uploadCmd.Flags().String("source-ref", "", "Branch, tag or commit of the source repository which is checked out (defaults to its default branch)")
+	uploadCmd.Flags().String("since", "", "Only package and upload the charts in charts-dir with files changed since this Git revision, e.g. the previous tag")
+	uploadCmd.Flags().Bool("package-with-dependency-update", true, "Update chart dependencies when packaging charts from the charts directory")
+	uploadCmd.Flags().StringSlice("dependency-repos", nil, "Helm repositories the chart dependencies are resolved from as name=url pairs, e.g. bitnami=https://charts.bitnami.com/bitnami, without running 'helm repo add' first")
+	uploadCmd.Flags().StringSlice("package-values", nil, "Values files whose values override the default values in values.yaml of the packaged charts, e.g. for per-environment releases")
+	uploadCmd.Flags().StringSlice("package-set", nil, "Values overriding the default values in values.yaml of the packaged charts, as key=value pairs like helm's --set")
 	uploadCmd.Flags().StringP("token", "t", "", "GitHub Auth Token")
-	uploadCmd.Flags().StringP("git-base-url", "b", "https://api.github.com/", "GitHub Base URL (only needed for private GitHub)")
-	uploadCmd.Flags().StringP("git-upload-url", "u", "https://uploads.github.com/", "GitHub Upload URL (only needed for private GitHub)")
+	uploadCmd.Flags().String("token-file", "", "File the GitHub Auth Token is read from, if --token is not set")
+	uploadCmd.Flags().String("token-command", "", "Command printing the GitHub Auth Token, e.g. a credential helper, if neither --token nor --token-file is set")
+	uploadCmd.Flags().Int("max-retries", 3, "Maximum number of retries for failed GitHub API calls")
+	uploadCmd.Flags().Duration("retry-delay", time.Second, "Base delay between retries of failed GitHub API calls, growing exponentially")
+	uploadCmd.Flags().Bool("rate-limit-pause", false, "Pause and resume when hitting GitHub's secondary rate limits instead of failing")
+	uploadCmd.Flags().String("user-agent", version.UserAgent(), "User-Agent header of the requests to GitHub and of the downloads of the index and chart packages")
+	uploadCmd.Flags().StringSlice("extra-headers", nil, "Extra headers of the requests to GitHub and of the downloads of the index and chart packages, e.g. 'X-Proxy-Token: secret'")
+	uploadCmd.Flags().String("proxy", "", "URL of the proxy for the requests to GitHub and the downloads of the index and chart packages (defaults to HTTPS_PROXY or HTTP_PROXY)")
+	uploadCmd.Flags().StringSlice("no-proxy", nil, "Hosts, domains and IP ranges which are not reached through the proxy, e.g. '.example.com' (defaults to NO_PROXY)")
+	uploadCmd.Flags().String("provider", "github", "The Git hosting provider the releases are created on (github, gitlab, gitea)")
+	uploadCmd.Flags().StringP("git-base-url", "b", "https://api.github.com/", "GitHub Base URL (only needed for private GitHub, defaults to https://gitlab.com/api/v4/ for GitLab, required for Gitea)")
+	uploadCmd.Flags().StringP("git-upload-url", "u", "", "GitHub Upload URL (only needed for private GitHub, defaults to https://uploads.github.com/ or the api/uploads/ endpoint of the GitHub Enterprise host)")
 	uploadCmd.Flags().StringP("commit", "c", "", "Target commit for release")
-	uploadCmd.Flags().Bool("skip-existing", false, "Skip upload if release exists")
-	uploadCmd.Flags().String("release-name-template", "{{ .Name }}-{{ .Version }}", "Go template for computing release names, using chart metadata")
+	uploadCmd.Flags().String("charts-repo", "", "The URL to the charts repository, used to verify that already published chart versions are not changed")
+	uploadCmd.Flags().String("remote-index-url", "", "URL of the existing index.yaml (defaults to index.yaml in the charts repository)")
+	uploadCmd.Flags().Bool("allow-changed-versions", false, "Allow releasing chart packages whose digest differs from the already published version")
+	uploadCmd.Flags().Bool("skip-existing", false, "Skip upload if release exists, only uploading assets missing from it")
+	uploadCmd.Flags().Bool("use-existing-release", false, "Upload the assets to the release of the tag if it exists already, e.g. because it was created manually, instead of failing")
+	uploadCmd.Flags().Bool("force", false, "DANGEROUS: delete an existing release of the tag, including its assets and the tag, and create it anew")
+	uploadCmd.Flags().Bool("mark-prerelease", false, "Mark all releases as prereleases (releases of SemVer prerelease versions are always marked)")
+	uploadCmd.Flags().Bool("draft", false, "Create the releases as drafts, which are left out of the index until they are published with 'cr index --publish-drafts'")
+	uploadCmd.Flags().String("make-release-latest", "", "Whether releases become the latest release of the repository (true, false, legacy), defaults to GitHub's behavior")
+	uploadCmd.Flags().String("discussion-category", "", "Name of the category of the GitHub Discussion opened for each release, which must exist in the repository (no discussion is opened if empty)")
+	uploadCmd.Flags().Bool("preflight-auth", false, "Check that the token can read the repository and create releases before releasing anything, failing with the missing permission otherwise")
+	uploadCmd.Flags().Int("max-concurrency", 1, "Maximum number of chart packages released in parallel")
+	uploadCmd.Flags().String("oci-registry", "", "OCI registry the chart packages are pushed to in addition to GitHub Releases (e.g. oci://ghcr.io/owner/charts)")
+	uploadCmd.Flags().String("webhook-url", "", "URL a JSON summary of the released charts is posted to after all releases succeeded, e.g. a Slack incoming webhook")
+	uploadCmd.Flags().Bool("sign", false, "Use a PGP private key to sign chart packages that have no provenance file yet")
+	uploadCmd.Flags().String("key", "", "Name of the key to use when signing")
+	uploadCmd.Flags().String("keyring", filepath.Join(dir, ".gnupg", "pubring.gpg"), "Location of a public keyring")
+	uploadCmd.Flags().String("passphrase-file", "", "Location of a file which contains the passphrase for the signing key. Use '-' in order to read from stdin")
+	uploadCmd.Flags().Bool("cosign", false, "Sign chart packages keylessly with sigstore using the cosign CLI and upload the .sig and .bundle files as release assets")
+	uploadCmd.Flags().Bool("generate-sbom", false, "Generate a CycloneDX SBOM listing the dependency charts and container images of every chart package and upload it as a .cdx.json release asset")
+	uploadCmd.Flags().String("release-name-template", "", "Go template for computing release names, using chart metadata and the .Path of the chart package (defaults to the release tag template)")
+	uploadCmd.Flags().StringSlice("template-env", nil, "Environment variables the env and expandenv template functions may read, e.g. PAGES_DOMAIN (can be specified multiple times, defaults to all)")
+	uploadCmd.Flags().String("release-tag-template", "", "Go template for computing release tags, using chart metadata and the .Path of the chart package (defaults to the release name template or \"{{ .Name }}-{{ .Version }}\")")
+	uploadCmd.Flags().String("asset-name-template", "", "Go template for computing the file names chart packages are uploaded as, using chart metadata and the .Path of the chart package, e.g. \"{{ .Name }}_{{ .Version }}.tgz\" (defaults to the file name of the package)")
+	uploadCmd.Flags().StringToString("chart-aliases", nil, "Published names of charts keyed by chart name, used for the release names, tags, asset names and index entries of the chart, e.g. internal-foo=foo")
+	uploadCmd.Flags().String("release-notes-template", "", "Go template for computing release notes, using chart metadata and the .URL and .Digest of the chart package (defaults to the chart description)")
+	uploadCmd.Flags().String("release-notes-file", "", "Name of a file in the chart, e.g. RELEASE.md, whose contents are used as release notes if no release notes template is set (defaults to the chart description)")
+	uploadCmd.Flags().StringSlice("extra-asset-globs", nil, "Glob patterns of files in the chart directories below charts-dir, e.g. values.schema.json, which are attached to the releases as well")
+	uploadCmd.Flags().Int64("max-asset-size", 0, "Maximum size in bytes of the assets of a release, rejecting larger chart packages and files before uploading them (no limit if 0)")
+	uploadCmd.Flags().Bool("generate-release-notes", false, "Let GitHub generate release notes from the commits since the release of the previous chart version in the index, following the release notes")
+	uploadCmd.Flags().Bool("dry-run", false, "Print the actions that would be taken instead of creating releases")
+	uploadCmd.Flags().String("log-format", "text", "Log output format (text, json)")
+	uploadCmd.Flags().Bool("verbose", false, "Log the time taken by each chart in every phase of the run")
+	uploadCmd.Flags().Duration("timeout", 0, "Maximum duration of the command, e.g. 10m (no limit by default)")
 }
@@ -0,0 +1,53 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/git"
+	"github.com/helm/chart-releaser/pkg/releaser"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate chart packages before releasing them",
+	Long: `
+Validate the Helm chart packages in the package path without contacting
+GitHub. Every package must contain a valid chart with a SemVer version
+matching its file name, and every chart version must be packaged only once.
+All problems found are reported together.
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := config.LoadConfiguration(cfgFile, cmd, nil)
+		if err != nil {
+			return err
+		}
+		releaser, err := releaser.NewReleaser(config, &git.Git{})
+		if err != nil {
+			return err
+		}
+		return releaser.Validate()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	flags := validateCmd.Flags()
+	flags.StringP("package-path", "p", ".cr-release-packages", "Path to directory with chart packages, multiple directories may be separated by commas")
+	flags.StringSlice("skip-charts", nil, "Glob patterns of chart names whose packages are skipped, e.g. '*-dev'")
+	flags.String("log-format", "text", "Log output format (text, json)")
+}
@@ -18,24 +18,18 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
-)
 
-var (
-	// GitCommit is updated with the Git tag by the Goreleaser build
-	GitCommit = "unknown"
-	// BuildDate is updated with the current ISO timestamp by the Goreleaser build
-	BuildDate = "unknown"
-	// Version is updated with the latest tag by the Goreleaser build
-	Version = "unreleased"
+	"github.com/helm/chart-releaser/pkg/version"
 )
 
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Version:\t", Version)
-		fmt.Println("Git commit:\t", GitCommit)
-		fmt.Println("Date:\t\t", BuildDate)
+		info := version.BuildInfo()
+		fmt.Println("Version:\t", info.Version)
+		fmt.Println("Git commit:\t", info.GitCommit)
+		fmt.Println("Date:\t\t", info.BuildDate)
 		fmt.Println("License:\t Apache 2.0")
 	},
 }
@@ -3,7 +3,10 @@ module github.com/helm/chart-releaser
 go 1.15
 
 require (
-	github.com/Songmu/retry v0.1.0
+	cloud.google.com/go/storage v1.12.0
+	github.com/Masterminds/semver/v3 v3.1.1
+	github.com/Masterminds/sprig/v3 v3.2.2
+	github.com/aws/aws-sdk-go v1.36.1
 	github.com/golangci/golangci-lint v1.37.0
 	github.com/google/go-github/v33 v33.0.0
 	github.com/goreleaser/goreleaser v0.156.2
@@ -14,6 +17,9 @@ require (
 	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.7.0
+	github.com/xanzy/go-gitlab v0.44.0
+	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
+	golang.org/x/net v0.0.0-20201209123823-ac852fbbde11
 	golang.org/x/oauth2 v0.0.0-20210216194517-16ff1888fd2e
 	golang.org/x/tools v0.1.0
 	helm.sh/helm/v3 v3.5.2
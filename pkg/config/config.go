@@ -15,10 +15,19 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
 	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 
@@ -38,31 +47,180 @@ var (
 )
 
 type Options struct {
-	Owner               string `mapstructure:"owner"`
-	GitRepo             string `mapstructure:"git-repo"`
-	ChartsRepo          string `mapstructure:"charts-repo"`
-	IndexPath           string `mapstructure:"index-path"`
-	PackagePath         string `mapstructure:"package-path"`
-	Sign                bool   `mapstructure:"sign"`
-	Key                 string `mapstructure:"key"`
-	KeyRing             string `mapstructure:"keyring"`
-	PassphraseFile      string `mapstructure:"passphrase-file"`
-	Token               string `mapstructure:"token"`
-	GitBaseURL          string `mapstructure:"git-base-url"`
-	GitUploadURL        string `mapstructure:"git-upload-url"`
-	Commit              string `mapstructure:"commit"`
-	PagesBranch         string `mapstructure:"pages-branch"`
-	Push                bool   `mapstructure:"push"`
-	PR                  bool   `mapstructure:"pr"`
-	Remote              string `mapstructure:"remote"`
-	ReleaseNameTemplate string `mapstructure:"release-name-template"`
-	SkipExisting        bool   `mapstructure:"skip-existing"`
+	Owner                       string            `mapstructure:"owner"`
+	GitRepo                     string            `mapstructure:"git-repo"`
+	ChartsRepo                  string            `mapstructure:"charts-repo"`
+	RemoteIndexURL              string            `mapstructure:"remote-index-url"`
+	IndexPath                   string            `mapstructure:"index-path"`
+	PackagePath                 string            `mapstructure:"package-path"`
+	SkipCharts                  []string          `mapstructure:"skip-charts"`
+	SkipDeprecated              bool              `mapstructure:"skip-deprecated"`
+	AllowEmpty                  bool              `mapstructure:"allow-empty"`
+	SkipIndex                   bool              `mapstructure:"skip-index"`
+	StateFile                   string            `mapstructure:"state-file"`
+	ValidateValuesSchema        bool              `mapstructure:"validate-values-schema"`
+	PreReleaseHook              string            `mapstructure:"pre-release-hook"`
+	VerifyProvenance            bool              `mapstructure:"verify-provenance"`
+	ChartsDir                   string            `mapstructure:"charts-dir"`
+	SourceRepo                  string            `mapstructure:"source-repo"`
+	SourceRef                   string            `mapstructure:"source-ref"`
+	Since                       string            `mapstructure:"since"`
+	PackageWithDependencyUpdate bool              `mapstructure:"package-with-dependency-update"`
+	DependencyRepos             []string          `mapstructure:"dependency-repos"`
+	PackageValues               []string          `mapstructure:"package-values"`
+	PackageSet                  []string          `mapstructure:"package-set"`
+	Sign                        bool              `mapstructure:"sign"`
+	Key                         string            `mapstructure:"key"`
+	KeyRing                     string            `mapstructure:"keyring"`
+	SignIndex                   bool              `mapstructure:"sign-index"`
+	PassphraseFile              string            `mapstructure:"passphrase-file"`
+	Cosign                      bool              `mapstructure:"cosign"`
+	GenerateSBOM                bool              `mapstructure:"generate-sbom"`
+	Token                       string            `mapstructure:"token"`
+	TokenFile                   string            `mapstructure:"token-file"`
+	TokenCommand                string            `mapstructure:"token-command"`
+	Provider                    string            `mapstructure:"provider"`
+	MaxRetries                  int               `mapstructure:"max-retries"`
+	RetryDelay                  time.Duration     `mapstructure:"retry-delay"`
+	RateLimitPause              bool              `mapstructure:"rate-limit-pause"`
+	UserAgent                   string            `mapstructure:"user-agent"`
+	ExtraHeaders                []string          `mapstructure:"extra-headers"`
+	Proxy                       string            `mapstructure:"proxy"`
+	NoProxy                     []string          `mapstructure:"no-proxy"`
+	StorageBackend              string            `mapstructure:"storage-backend"`
+	StorageBucket               string            `mapstructure:"storage-bucket"`
+	StoragePrefix               string            `mapstructure:"storage-prefix"`
+	S3Region                    string            `mapstructure:"s3-region"`
+	IndexMirrors                []string          `mapstructure:"index-mirrors"`
+	MirrorPackages              bool              `mapstructure:"mirror-packages"`
+	PublishDrafts               bool              `mapstructure:"publish-drafts"`
+	GitBaseURL                  string            `mapstructure:"git-base-url"`
+	GitUploadURL                string            `mapstructure:"git-upload-url"`
+	Commit                      string            `mapstructure:"commit"`
+	PagesBranch                 string            `mapstructure:"pages-branch"`
+	PagesIndexPath              string            `mapstructure:"pages-index-path"`
+	UnstableIndexPath           string            `mapstructure:"unstable-index-path"`
+	UnstablePagesIndexPath      string            `mapstructure:"unstable-pages-index-path"`
+	IndexRouting                map[string]string `mapstructure:"index-routing"`
+	CommitMessageTemplate       string            `mapstructure:"commit-message-template"`
+	PackagesWithIndex           bool              `mapstructure:"packages-with-index"`
+	BaseURL                     string            `mapstructure:"base-url"`
+	URLTemplate                 string            `mapstructure:"url-template"`
+	MirrorBaseURLs              []string          `mapstructure:"mirror-base-urls"`
+	TemplateEnv                 []string          `mapstructure:"template-env"`
+	ChartAliases                map[string]string `mapstructure:"chart-aliases"`
+	AssetNameTemplate           string            `mapstructure:"asset-name-template"`
+	StableGenerated             bool              `mapstructure:"stable-generated"`
+	GeneratedTimestamp          string            `mapstructure:"generated-timestamp"`
+	RebuildIndex                bool              `mapstructure:"rebuild-index"`
+	OnlyCharts                  []string          `mapstructure:"only-charts"`
+	FailOnIndexShrink           bool              `mapstructure:"fail-on-index-shrink"`
+	AllowIndexShrink            bool              `mapstructure:"allow-index-shrink"`
+	WriteManifest               bool              `mapstructure:"write-manifest"`
+	GenerateHTML                bool              `mapstructure:"generate-html"`
+	GzipIndex                   bool              `mapstructure:"gzip-index"`
+	HTMLTemplate                string            `mapstructure:"html-template"`
+	Push                        bool              `mapstructure:"push"`
+	PR                          bool              `mapstructure:"pr"`
+	Remote                      string            `mapstructure:"remote"`
+	GitPushMode                 string            `mapstructure:"git-push-mode"`
+	WorktreeDir                 string            `mapstructure:"worktree-dir"`
+	GitUserName                 string            `mapstructure:"git-user-name"`
+	GitUserEmail                string            `mapstructure:"git-user-email"`
+	SignCommits                 bool              `mapstructure:"sign-commits"`
+	CommitSigningKey            string            `mapstructure:"commit-signing-key"`
+	ReleaseNameTemplate         string            `mapstructure:"release-name-template"`
+	ReleaseTagTemplate          string            `mapstructure:"release-tag-template"`
+	ReleaseNotesTemplate        string            `mapstructure:"release-notes-template"`
+	ReleaseNotesFile            string            `mapstructure:"release-notes-file"`
+	ExtraAssetGlobs             []string          `mapstructure:"extra-asset-globs"`
+	MaxAssetSize                int64             `mapstructure:"max-asset-size"`
+	GenerateReleaseNotes        bool              `mapstructure:"generate-release-notes"`
+	SkipExisting                bool              `mapstructure:"skip-existing"`
+	UseExistingRelease          bool              `mapstructure:"use-existing-release"`
+	Force                       bool              `mapstructure:"force"`
+	MarkPrerelease              bool              `mapstructure:"mark-prerelease"`
+	Draft                       bool              `mapstructure:"draft"`
+	MakeReleaseLatest           string            `mapstructure:"make-release-latest"`
+	DiscussionCategory          string            `mapstructure:"discussion-category"`
+	PreflightAuth               bool              `mapstructure:"preflight-auth"`
+	PruneKeepLast               int               `mapstructure:"prune-keep-last"`
+	PruneMaxAge                 time.Duration     `mapstructure:"prune-max-age"`
+	PruneIndex                  bool              `mapstructure:"prune-index"`
+	AllowChangedVersions        bool              `mapstructure:"allow-changed-versions"`
+	MaxConcurrency              int               `mapstructure:"max-concurrency"`
+	OCIRegistry                 string            `mapstructure:"oci-registry"`
+	WebhookURL                  string            `mapstructure:"webhook-url"`
+	DryRun                      bool              `mapstructure:"dry-run"`
+	LogFormat                   string            `mapstructure:"log-format"`
+	Verbose                     bool              `mapstructure:"verbose"`
+	Timeout                     time.Duration     `mapstructure:"timeout"`
+	// Charts holds options overriding the global ones for the chart of the
+	// given name, e.g. its release-name-template. Only the options listed in
+	// ChartOptions may be overridden.
+	Charts map[string]map[string]interface{} `mapstructure:"charts"`
 }
 
+// ChartOptions lists the options which may be overridden per chart.
+var ChartOptions = []string{
+	"release-name-template",
+	"release-tag-template",
+	"release-notes-template",
+	"release-notes-file",
+	"mark-prerelease",
+	"make-release-latest",
+	"skip-existing",
+	"use-existing-release",
+	"extra-asset-globs",
+	"sign",
+	"key",
+	"verify-provenance",
+}
+
+// ForChart returns the options for the chart of the given name, i.e. the
+// global options with the overrides of the chart merged over them. Chart
+// names are matched case-insensitively, since the keys of the config file
+// are.
+func (o *Options) ForChart(name string) (*Options, error) {
+	overrides := o.Charts[strings.ToLower(name)]
+	if len(overrides) == 0 {
+		return o, nil
+	}
+	v := viper.New()
+	if err := v.MergeConfigMap(overrides); err != nil {
+		return nil, errors.Wrapf(err, "invalid options of chart %s", name)
+	}
+	// the overrides are decoded on their own and assigned field by field, as
+	// decoding them over the copy would write into the slices and maps it
+	// shares with the global options
+	var overridden Options
+	if err := v.Unmarshal(&overridden); err != nil {
+		return nil, errors.Wrapf(err, "invalid options of chart %s", name)
+	}
+	opts := *o
+	for key := range overrides {
+		if f, ok := opts.field(strings.ToLower(key)); ok {
+			value, _ := overridden.field(strings.ToLower(key))
+			f.Set(value)
+		}
+	}
+	return &opts, nil
+}
+
+// LoadConfiguration loads the options of the given command with Load.
 func LoadConfiguration(cfgFile string, cmd *cobra.Command, requiredFlags []string) (*Options, error) {
+	return Load(cfgFile, cmd.Flags(), requiredFlags)
+}
+
+// Load loads the options from the flags, the CR_ environment variables, e.g.
+// CR_GIT_REPO for --git-repo, and the config file, in this order of
+// precedence, falling back to the defaults of the flags. Without cfgFile,
+// cr.yaml is looked up in the current directory, ~/.cr and /etc/cr. The
+// options are validated with Validate.
+func Load(cfgFile string, flags *flag.FlagSet, requiredFlags []string) (*Options, error) {
 	v := viper.New()
 
-	cmd.Flags().VisitAll(func(flag *flag.Flag) {
+	flags.VisitAll(func(flag *flag.Flag) {
 		flagName := flag.Name
 		if flagName != "config" && flagName != "help" {
 			if err := v.BindPFlag(flagName, flag); err != nil {
@@ -99,36 +257,218 @@ func LoadConfiguration(cfgFile string, cmd *cobra.Command, requiredFlags []strin
 		return nil, errors.Wrap(err, "Error unmarshaling configuration")
 	}
 
-	if opts.Push && opts.PR {
-		return nil, errors.New("specify either --push or --pr, but not both")
+	if err := opts.ResolveToken(); err != nil {
+		return nil, err
+	}
+	if opts.GeneratedTimestamp == "" && flags.Lookup("generated-timestamp") != nil {
+		// https://reproducible-builds.org/specs/source-date-epoch/, only
+		// applied to the commands writing the index
+		opts.GeneratedTimestamp = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+	if err := opts.Validate(requiredFlags); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// GeneratedTime returns the time the index is marked as generated at, which is
+// GeneratedTimestamp if it is set and the current time otherwise. It fails if
+// GeneratedTimestamp is neither a Unix time nor an RFC 3339 time.
+func (o *Options) GeneratedTime() (time.Time, error) {
+	if o.GeneratedTimestamp == "" {
+		return time.Now(), nil
+	}
+	generated, err := parseTimestamp(o.GeneratedTimestamp)
+	if err != nil {
+		return time.Time{}, errors.Errorf("--generated-timestamp %q must be a Unix time in seconds or an RFC 3339 time", o.GeneratedTimestamp)
+	}
+	return generated, nil
+}
+
+// parseTimestamp parses a Unix time in seconds or an RFC 3339 time.
+func parseTimestamp(timestamp string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, timestamp)
+}
+
+// ResolveToken reads the token from TokenFile or the output of TokenCommand
+// unless it is set explicitly, in this order of precedence, so that it does
+// not have to be passed on the command line. Load calls it, as does the
+// releaser for options which are not loaded.
+func (o *Options) ResolveToken() error {
+	switch {
+	case o.Token != "":
+	case o.TokenFile != "":
+		token, err := ioutil.ReadFile(o.TokenFile)
+		if err != nil {
+			return errors.Wrap(err, "error reading token file")
+		}
+		o.Token = strings.TrimSpace(string(token))
+		if o.Token == "" {
+			return errors.Errorf("token file %s is empty", o.TokenFile)
+		}
+	case o.TokenCommand != "":
+		var stderr bytes.Buffer
+		command := ShellCommand(o.TokenCommand)
+		command.Stderr = &stderr
+		token, err := command.Output()
+		if err != nil {
+			if output := strings.TrimSpace(stderr.String()); output != "" {
+				return errors.Wrapf(err, "error running token command: %s", output)
+			}
+			return errors.Wrap(err, "error running token command")
+		}
+		o.Token = strings.TrimSpace(string(token))
+		if o.Token == "" {
+			return errors.New("token command printed no token")
+		}
+	}
+	return nil
+}
+
+// ShellCommand returns the command running the given command line with the
+// shell of the platform, i.e. sh, or cmd on Windows. The token command and
+// the pre-release hook are run with it.
+func ShellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
 	}
+	return exec.Command("sh", "-c", command)
+}
 
-	elem := reflect.ValueOf(opts).Elem()
+// Validate makes sure that the options named by requiredFlags are set and
+// that the options are consistent, e.g. that pushing the index has a token.
+// All problems are reported at once.
+func (o *Options) Validate(requiredFlags []string) error {
+	var problems []string
+
+	var missing, envVars []string
 	for _, requiredFlag := range requiredFlags {
-		fieldName := kebabCaseToTitleCamelCase(requiredFlag)
-		f := elem.FieldByName(fieldName)
-		value := fmt.Sprintf("%v", f.Interface())
-		if value == "" {
-			return nil, errors.Errorf("'--%s' is required", requiredFlag)
+		f, ok := o.field(requiredFlag)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown option '--%s'", requiredFlag))
+			continue
+		}
+		if f.IsZero() || (f.Kind() == reflect.Slice && f.Len() == 0) {
+			missing = append(missing, "'--"+requiredFlag+"'")
+			envVars = append(envVars, "CR_"+strings.ToUpper(strings.ReplaceAll(requiredFlag, "-", "_")))
 		}
 	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		problems = append(problems, fmt.Sprintf("%s is required, set it as a flag, as %s or in the config file", missing[0], envVars[0]))
+	default:
+		problems = append(problems, fmt.Sprintf("%s are required, set them as flags, as %s or in the config file",
+			strings.Join(missing, ", "), strings.Join(envVars, ", ")))
+	}
 
-	return opts, nil
+	if o.Push && o.PR {
+		problems = append(problems, "specify either --push or --pr, but not both")
+	}
+	if o.Force && (o.SkipExisting || o.UseExistingRelease) {
+		problems = append(problems, "--force can not be combined with --skip-existing or --use-existing-release")
+	}
+	if o.DiscussionCategory != "" {
+		if strings.TrimSpace(o.DiscussionCategory) != o.DiscussionCategory {
+			problems = append(problems, fmt.Sprintf("--discussion-category %q must not have leading or trailing spaces", o.DiscussionCategory))
+		}
+		if o.Provider != "" && o.Provider != "github" {
+			problems = append(problems, fmt.Sprintf("--discussion-category is not supported by the %s provider", o.Provider))
+		}
+	}
+	if _, err := o.GeneratedTime(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	prefixes := make([]string, 0, len(o.IndexRouting))
+	for prefix := range o.IndexRouting {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		route := o.IndexRouting[prefix]
+		if path.Base(route) != "index.yaml" || path.IsAbs(route) || strings.HasPrefix(path.Clean(route), "..") {
+			problems = append(problems, fmt.Sprintf("--index-routing path %q of prefix %s must be a relative path of an index.yaml in the GitHub Pages branch", route, prefix))
+		}
+	}
+	aliases := make([]string, 0, len(o.ChartAliases))
+	for name := range o.ChartAliases {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+	for _, name := range aliases {
+		if alias := o.ChartAliases[name]; alias == "" || strings.ContainsAny(alias, "/\\ ") {
+			problems = append(problems, fmt.Sprintf("--chart-aliases alias %q of chart %s must be a non-empty chart name", alias, name))
+		}
+	}
+	if o.PreflightAuth && o.Provider != "" && o.Provider != "github" {
+		problems = append(problems, fmt.Sprintf("--preflight-auth is not supported by the %s provider", o.Provider))
+	}
+	if (o.Push || o.PR) && (o.Owner == "" || o.GitRepo == "") {
+		problems = append(problems, "'--owner' and '--git-repo' are required for pushing with --push or --pr")
+	}
+	switch {
+	case o.PR && o.Token == "":
+		problems = append(problems, "'--token' is required for opening a pull request with --pr")
+	case o.Push && o.Token == "" && o.GitPushMode != "ssh":
+		problems = append(problems, "'--token' is required for pushing with --push, unless --git-push-mode is ssh")
+	}
+	problems = append(problems, o.validateCharts()...)
+
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(problems[0])
+	default:
+		return errors.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
 }
 
-func kebabCaseToTitleCamelCase(input string) (result string) {
-	nextToUpper := true
-	for _, runeValue := range input {
-		if nextToUpper {
-			result += strings.ToUpper(string(runeValue))
-			nextToUpper = false
-		} else {
-			if runeValue == '-' {
-				nextToUpper = true
-			} else {
-				result += string(runeValue)
+// validateCharts makes sure that the per-chart overrides only set options
+// listed in ChartOptions, with valid values.
+func (o *Options) validateCharts() []string {
+	names := make([]string, 0, len(o.Charts))
+	for name := range o.Charts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	allowed := make(map[string]bool, len(ChartOptions))
+	for _, option := range ChartOptions {
+		allowed[option] = true
+	}
+	var problems []string
+	for _, name := range names {
+		var unknown []string
+		for option := range o.Charts[name] {
+			if !allowed[option] {
+				unknown = append(unknown, option)
 			}
 		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			problems = append(problems, fmt.Sprintf("%s can not be set for chart %s, only %s can",
+				strings.Join(unknown, ", "), name, strings.Join(ChartOptions, ", ")))
+			continue
+		}
+		if _, err := o.ForChart(name); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// field returns the field of the option with the given name, i.e. its
+// mapstructure key.
+func (o *Options) field(name string) (reflect.Value, bool) {
+	elem := reflect.ValueOf(o).Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		if elem.Type().Field(i).Tag.Get("mapstructure") == name {
+			return elem.Field(i), true
+		}
 	}
-	return
+	return reflect.Value{}, false
 }
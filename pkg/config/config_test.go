@@ -0,0 +1,400 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "cr.yaml")
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte("owner: file-owner\ngit-repo: file-repo\ncharts-repo: https://file.example.com\n"), 0644))
+
+	tests := []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		owner      string
+		gitRepo    string
+		chartsRepo string
+	}{
+		{
+			name:       "config-file",
+			owner:      "file-owner",
+			gitRepo:    "file-repo",
+			chartsRepo: "https://file.example.com",
+		},
+		{
+			name:       "env-over-config-file",
+			env:        map[string]string{"CR_OWNER": "env-owner", "CR_GIT_REPO": "env-repo"},
+			owner:      "env-owner",
+			gitRepo:    "env-repo",
+			chartsRepo: "https://file.example.com",
+		},
+		{
+			name:       "flags-over-env",
+			args:       []string{"--owner", "flag-owner"},
+			env:        map[string]string{"CR_OWNER": "env-owner", "CR_GIT_REPO": "env-repo"},
+			owner:      "flag-owner",
+			gitRepo:    "env-repo",
+			chartsRepo: "https://file.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				assert.NoError(t, os.Setenv(key, value))
+				defer os.Unsetenv(key)
+			}
+			flags := testFlags()
+			assert.NoError(t, flags.Parse(tt.args))
+
+			opts, err := Load(configFile, flags, []string{"owner", "git-repo", "charts-repo"})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.owner, opts.Owner)
+			assert.Equal(t, tt.gitRepo, opts.GitRepo)
+			assert.Equal(t, tt.chartsRepo, opts.ChartsRepo)
+			assert.Equal(t, "index.yaml", opts.IndexPath)
+		})
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+	emptyFile := filepath.Join(dir, "empty")
+	assert.NoError(t, ioutil.WriteFile(emptyFile, nil, 0600))
+	configFile := filepath.Join(dir, "cr.yaml")
+	assert.NoError(t, ioutil.WriteFile(configFile, nil, 0644))
+
+	tests := []struct {
+		name  string
+		args  []string
+		token string
+		error string
+	}{
+		{
+			name:  "token",
+			args:  []string{"--token", "flag-token", "--token-file", tokenFile, "--token-command", "echo command-token"},
+			token: "flag-token",
+		},
+		{
+			name:  "file-over-command",
+			args:  []string{"--token-file", tokenFile, "--token-command", "echo command-token"},
+			token: "file-token",
+		},
+		{
+			name:  "command",
+			args:  []string{"--token-command", "echo command-token"},
+			token: "command-token",
+		},
+		{
+			name:  "missing-file",
+			args:  []string{"--token-file", filepath.Join(dir, "missing")},
+			error: "error reading token file: open " + filepath.Join(dir, "missing") + ": no such file or directory",
+		},
+		{
+			name:  "empty-file",
+			args:  []string{"--token-file", emptyFile},
+			error: "token file " + emptyFile + " is empty",
+		},
+		{
+			name:  "failing-command",
+			args:  []string{"--token-command", "echo not logged in >&2; exit 1"},
+			error: "error running token command: not logged in: exit status 1",
+		},
+		{
+			name:  "silent-command",
+			args:  []string{"--token-command", "true"},
+			error: "token command printed no token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := testFlags()
+			assert.NoError(t, flags.Parse(tt.args))
+
+			opts, err := Load(configFile, flags, []string{"token"})
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.token, opts.Token)
+		})
+	}
+}
+
+func TestLoadGeneratedTimestamp(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "cr.yaml")
+	assert.NoError(t, ioutil.WriteFile(configFile, nil, 0644))
+
+	tests := []struct {
+		name      string
+		args      []string
+		epoch     string
+		timestamp string
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:      "source-date-epoch",
+			epoch:     "1700000000",
+			timestamp: "1700000000",
+		},
+		{
+			name:      "flag-over-source-date-epoch",
+			args:      []string{"--generated-timestamp", "2024-01-01T00:00:00Z"},
+			epoch:     "1700000000",
+			timestamp: "2024-01-01T00:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.epoch != "" {
+				assert.NoError(t, os.Setenv("SOURCE_DATE_EPOCH", tt.epoch))
+				defer os.Unsetenv("SOURCE_DATE_EPOCH")
+			}
+			flags := testFlags()
+			assert.NoError(t, flags.Parse(tt.args))
+
+			opts, err := Load(configFile, flags, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.timestamp, opts.GeneratedTimestamp)
+		})
+	}
+
+	// commands which do not write the index ignore SOURCE_DATE_EPOCH
+	assert.NoError(t, os.Setenv("SOURCE_DATE_EPOCH", "yesterday"))
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+	flags := flag.NewFlagSet("upload", flag.ContinueOnError)
+	flags.String("owner", "", "GitHub username or organization")
+	opts, err := Load(configFile, flags, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, opts.GeneratedTimestamp)
+	_, err = Load(configFile, testFlags(), nil)
+	assert.EqualError(t, err, `--generated-timestamp "yesterday" must be a Unix time in seconds or an RFC 3339 time`)
+}
+
+func TestOptions_GeneratedTime(t *testing.T) {
+	opts := &Options{GeneratedTimestamp: "1700000000"}
+	generated, err := opts.GeneratedTime()
+	assert.NoError(t, err)
+	assert.True(t, generated.Equal(time.Unix(1700000000, 0)))
+
+	opts.GeneratedTimestamp = "2024-01-01T00:00:00Z"
+	generated, err = opts.GeneratedTime()
+	assert.NoError(t, err)
+	assert.True(t, generated.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	opts.GeneratedTimestamp = "yesterday"
+	_, err = opts.GeneratedTime()
+	assert.EqualError(t, err, `--generated-timestamp "yesterday" must be a Unix time in seconds or an RFC 3339 time`)
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          Options
+		requiredFlags []string
+		error         string
+	}{
+		{
+			name:          "valid",
+			opts:          Options{Owner: "owner", GitRepo: "repo", Token: "token", Push: true},
+			requiredFlags: []string{"owner", "git-repo", "token"},
+		},
+		{
+			name:          "one-missing",
+			opts:          Options{Owner: "owner", GitRepo: "repo"},
+			requiredFlags: []string{"owner", "git-repo", "token"},
+			error:         "'--token' is required, set it as a flag, as CR_TOKEN or in the config file",
+		},
+		{
+			name:          "all-missing",
+			requiredFlags: []string{"owner", "git-repo", "remote-index-url"},
+			error:         "'--owner', '--git-repo', '--remote-index-url' are required, set them as flags, as CR_OWNER, CR_GIT_REPO, CR_REMOTE_INDEX_URL or in the config file",
+		},
+		{
+			name:  "push-without-token",
+			opts:  Options{Owner: "owner", GitRepo: "repo", Push: true},
+			error: "'--token' is required for pushing with --push, unless --git-push-mode is ssh",
+		},
+		{
+			name: "push-with-ssh",
+			opts: Options{Owner: "owner", GitRepo: "repo", Push: true, GitPushMode: "ssh"},
+		},
+		{
+			name:  "pr-with-ssh-without-token",
+			opts:  Options{Owner: "owner", GitRepo: "repo", PR: true, GitPushMode: "ssh"},
+			error: "'--token' is required for opening a pull request with --pr",
+		},
+		{
+			name: "pr-with-token",
+			opts: Options{Owner: "owner", GitRepo: "repo", Token: "token", PR: true},
+		},
+		{
+			name:  "push-without-owner",
+			opts:  Options{GitRepo: "repo", Token: "token", Push: true},
+			error: "'--owner' and '--git-repo' are required for pushing with --push or --pr",
+		},
+		{
+			name:  "pr-without-git-repo",
+			opts:  Options{Owner: "owner", Token: "token", PR: true},
+			error: "'--owner' and '--git-repo' are required for pushing with --push or --pr",
+		},
+		{
+			name:  "force-with-skip-existing",
+			opts:  Options{Force: true, SkipExisting: true},
+			error: "--force can not be combined with --skip-existing or --use-existing-release",
+		},
+		{
+			name: "discussion-category",
+			opts: Options{DiscussionCategory: "Announcements", Provider: "github"},
+		},
+		{
+			name:  "discussion-category-with-spaces",
+			opts:  Options{DiscussionCategory: " Announcements"},
+			error: `--discussion-category " Announcements" must not have leading or trailing spaces`,
+		},
+		{
+			name:  "discussion-category-on-gitlab",
+			opts:  Options{DiscussionCategory: "Announcements", Provider: "gitlab"},
+			error: "--discussion-category is not supported by the gitlab provider",
+		},
+		{
+			name:  "preflight-auth-on-gitea",
+			opts:  Options{PreflightAuth: true, Provider: "gitea"},
+			error: "--preflight-auth is not supported by the gitea provider",
+		},
+		{
+			name: "generated-timestamp",
+			opts: Options{GeneratedTimestamp: "2024-01-01T00:00:00Z"},
+		},
+		{
+			name:  "invalid-generated-timestamp",
+			opts:  Options{GeneratedTimestamp: "yesterday"},
+			error: `--generated-timestamp "yesterday" must be a Unix time in seconds or an RFC 3339 time`,
+		},
+		{
+			name:  "index-routing-outside-pages-branch",
+			opts:  Options{IndexRouting: map[string]string{"team-a-": "../team-a/index.yaml"}},
+			error: `--index-routing path "../team-a/index.yaml" of prefix team-a- must be a relative path of an index.yaml in the GitHub Pages branch`,
+		},
+		{
+			name: "chart-aliases",
+			opts: Options{ChartAliases: map[string]string{"internal-foo": "foo"}},
+		},
+		{
+			name:  "chart-aliases-with-path",
+			opts:  Options{ChartAliases: map[string]string{"internal-foo": "charts/foo"}},
+			error: `--chart-aliases alias "charts/foo" of chart internal-foo must be a non-empty chart name`,
+		},
+		{
+			name:          "several-problems",
+			opts:          Options{Owner: "owner", GitRepo: "repo", Push: true, PR: true, Token: "token"},
+			requiredFlags: []string{"charts-repo"},
+			error:         "invalid configuration:\n  '--charts-repo' is required, set it as a flag, as CR_CHARTS_REPO or in the config file\n  specify either --push or --pr, but not both",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate(tt.requiredFlags)
+			if tt.error == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.error)
+			}
+		})
+	}
+}
+
+func TestLoadChartOverrides(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "cr.yaml")
+	assert.NoError(t, ioutil.WriteFile(configFile, []byte(`release-name-template: "{{ .Name }}-{{ .Version }}"
+charts:
+  redis:
+    release-name-template: "redis v{{ .Version }}"
+    skip-existing: true
+    extra-asset-globs: [values.schema.json, README.md]
+`), 0644))
+
+	opts, err := Load(configFile, testFlags(), nil)
+	assert.NoError(t, err)
+
+	redis, err := opts.ForChart("Redis")
+	assert.NoError(t, err)
+	assert.Equal(t, "redis v{{ .Version }}", redis.ReleaseNameTemplate)
+	assert.True(t, redis.SkipExisting)
+	assert.Equal(t, []string{"values.schema.json", "README.md"}, redis.ExtraAssetGlobs)
+	assert.Equal(t, "index.yaml", redis.IndexPath)
+
+	other, err := opts.ForChart("other")
+	assert.NoError(t, err)
+	assert.Equal(t, "{{ .Name }}-{{ .Version }}", other.ReleaseNameTemplate)
+	assert.False(t, other.SkipExisting)
+	assert.Equal(t, "{{ .Name }}-{{ .Version }}", opts.ReleaseNameTemplate)
+}
+
+func TestOptions_ForChartKeepsGlobalOptions(t *testing.T) {
+	opts := &Options{
+		ExtraAssetGlobs: []string{"a", "b", "c"},
+		Charts: map[string]map[string]interface{}{
+			"redis": {"extra-asset-globs": []interface{}{"x"}},
+		},
+	}
+
+	redis, err := opts.ForChart("redis")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"x"}, redis.ExtraAssetGlobs)
+	assert.Equal(t, []string{"a", "b", "c"}, opts.ExtraAssetGlobs)
+
+	other, err := opts.ForChart("other")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, other.ExtraAssetGlobs)
+}
+
+func TestOptions_ValidateChartOverrides(t *testing.T) {
+	opts := Options{Charts: map[string]map[string]interface{}{
+		"redis": {"owner": "someone-else", "token": "secret", "skip-existing": true},
+		"web":   {"skip-existing": "maybe"},
+	}}
+	err := opts.Validate(nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "owner, token can not be set for chart redis")
+	assert.Contains(t, err.Error(), "invalid options of chart web")
+}
+
+func testFlags() *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.StringP("owner", "o", "", "GitHub username or organization")
+	flags.StringP("git-repo", "r", "", "GitHub repository")
+	flags.StringP("charts-repo", "c", "", "The URL to the charts repository")
+	flags.String("index-path", "index.yaml", "Path to index file")
+	flags.StringP("token", "t", "", "GitHub Auth Token")
+	flags.String("token-file", "", "File the GitHub Auth Token is read from")
+	flags.String("token-command", "", "Command printing the GitHub Auth Token")
+	flags.String("generated-timestamp", "", "Time index.yaml is marked as generated at")
+	return flags
+}
@@ -0,0 +1,42 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"os/exec"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// Cosign signs files keylessly with sigstore using the cosign CLI. The cosign
+// Go libraries require a far newer Go version and dependency set than the
+// Helm version we depend on allows.
+type Cosign struct {
+	// Logger logs the output of cosign, as events of the cosign action.
+	Logger *logging.Logger
+}
+
+// SignBlob runs 'cosign sign-blob' for the given file, writing the signature
+// and the sigstore bundle, which contains the signing certificate and the
+// transparency log entry, to the given paths. The identity token is obtained
+// by cosign, e.g. from the ambient credentials of GitHub Actions.
+func (c *Cosign) SignBlob(file string, signature string, bundle string) error {
+	command := exec.Command("cosign", "sign-blob", "--yes", "--output-signature", signature, "--bundle", bundle, file)
+	out := c.Logger.Writer("cosign", logging.Fields{"file": file})
+	defer out.Close()
+	command.Stdout = out
+	command.Stderr = out
+	return command.Run()
+}
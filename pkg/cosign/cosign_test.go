@@ -0,0 +1,98 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// fakeCosign puts a cosign script on the PATH which records its arguments,
+// prints a line and exits with the given code.
+func fakeCosign(t *testing.T, exitCode string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake cosign is a shell script")
+	}
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + args + "\necho 'Wrote signature'\necho 'tlog entry created' >&2\nexit " + exitCode + "\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cosign"), []byte(script), 0755))
+	path := os.Getenv("PATH")
+	require.NoError(t, os.Setenv("PATH", dir+string(os.PathListSeparator)+path))
+	t.Cleanup(func() {
+		os.Setenv("PATH", path)
+	})
+	return args
+}
+
+func TestCosign_SignBlob(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode string
+		error    bool
+	}{
+		{
+			name:     "signed",
+			exitCode: "0",
+		},
+		{
+			name:     "failed",
+			exitCode: "1",
+			error:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := fakeCosign(t, tt.exitCode)
+			var out bytes.Buffer
+			logger, err := logging.New(logging.FormatJSON, &out)
+			require.NoError(t, err)
+
+			c := &Cosign{Logger: logger}
+			err = c.SignBlob("test-chart-0.1.0.tgz", "test-chart-0.1.0.tgz.sig", "test-chart-0.1.0.tgz.bundle")
+			if tt.error {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			recorded, err := ioutil.ReadFile(args)
+			require.NoError(t, err)
+			assert.Equal(t, "sign-blob --yes --output-signature test-chart-0.1.0.tgz.sig --bundle test-chart-0.1.0.tgz.bundle test-chart-0.1.0.tgz\n", string(recorded))
+
+			// the output of cosign is logged instead of written to stdout
+			var msgs []string
+			decoder := json.NewDecoder(&out)
+			for decoder.More() {
+				var event map[string]interface{}
+				require.NoError(t, decoder.Decode(&event))
+				assert.Equal(t, "cosign", event["action"])
+				assert.Equal(t, "test-chart-0.1.0.tgz", event["file"])
+				msgs = append(msgs, event["msg"].(string))
+			}
+			assert.ElementsMatch(t, []string{"Wrote signature", "tlog entry created"}, msgs)
+		})
+	}
+}
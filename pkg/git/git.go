@@ -17,31 +17,125 @@ package git
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"sync"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+const (
+	// DefaultUserName and DefaultUserEmail are the identity index commits are
+	// made with if neither the Git struct nor the Git configuration sets one.
+	DefaultUserName  = "chart-releaser[bot]"
+	DefaultUserEmail = "chart-releaser[bot]@users.noreply.github.com"
+)
+
+const (
+	// PushModeHTTPS pushes via HTTPS, authenticating with the token.
+	PushModeHTTPS = "https"
+	// PushModeSSH pushes via SSH, authenticating with the SSH keys of the
+	// environment, e.g. a deploy key.
+	PushModeSSH = "ssh"
 )
 
-type Git struct{}
+type Git struct {
+	// TokenUser is the user name put in front of the token in push URLs. It
+	// defaults to "x-access-token", which is what GitHub expects.
+	TokenUser string
+
+	// PushMode is either PushModeHTTPS, the default, or PushModeSSH.
+	PushMode string
+
+	// UserName and UserEmail are the author and committer identity of
+	// commits. If empty, the Git configuration applies, falling back to
+	// DefaultUserName and DefaultUserEmail.
+	UserName  string
+	UserEmail string
+
+	// SignCommits makes commits GPG-signed with SigningKey, or with the key
+	// matching the committer identity if it is empty.
+	SignCommits bool
+	SigningKey  string
+
+	// WorktreeDir is the directory worktrees are created in. It defaults to
+	// the directory for temporary files and is created if it does not exist.
+	WorktreeDir string
+
+	// Logger logs the output of the git commands, stdout if it is nil.
+	Logger *logging.Logger
+
+	// worktreeMu serializes worktree operations, which modify the shared
+	// administrative files of the repository and must not run concurrently.
+	worktreeMu sync.Mutex
+}
 
 // AddWorktree creates a new Git worktree with a detached HEAD for the given committish and returns its path.
 func (g *Git) AddWorktree(workingDir string, committish string) (string, error) {
-	dir, err := ioutil.TempDir("", "chart-releaser-")
+	if g.WorktreeDir != "" {
+		if err := os.MkdirAll(g.WorktreeDir, 0755); err != nil {
+			return "", err
+		}
+	}
+	dir, err := ioutil.TempDir(g.WorktreeDir, "chart-releaser-")
 	if err != nil {
 		return "", err
 	}
 	command := exec.Command("git", "worktree", "add", "--detach", dir, committish)
 
-	if err := runCommand(workingDir, command); err != nil {
+	g.worktreeMu.Lock()
+	defer g.worktreeMu.Unlock()
+	if err := g.runCommand(workingDir, command); err != nil {
 		return "", err
 	}
 	return dir, nil
 }
 
+// Clone clones the repository at the given URL into a new directory and
+// checks out the given committish with a detached HEAD if it is set. The token
+// is used for HTTPS URLs. It returns the path of the clone, which the caller
+// removes when done.
+func (g *Git) Clone(repoURL string, token string, committish string) (string, error) {
+	cloneURL := repoURL
+	if token != "" && strings.HasPrefix(repoURL, "https://") {
+		var err error
+		if cloneURL, err = pushURLWithToken(repoURL, g.tokenUser(), token); err != nil {
+			return "", err
+		}
+	}
+	if g.WorktreeDir != "" {
+		if err := os.MkdirAll(g.WorktreeDir, 0755); err != nil {
+			return "", err
+		}
+	}
+	dir, err := ioutil.TempDir(g.WorktreeDir, "chart-releaser-source-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := g.runCommand("", exec.Command("git", "clone", "--quiet", cloneURL, dir)); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("could not clone %s: %v", repoURL, err)
+	}
+	if committish != "" {
+		if err := g.runCommand(dir, exec.Command("git", "checkout", "--quiet", "--detach", committish)); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("could not check out %s of %s: %v", committish, repoURL, err)
+		}
+	}
+	return dir, nil
+}
+
 // RemoveWorktree removes the Git worktree with the given path.
 func (g *Git) RemoveWorktree(workingDir string, path string) error {
 	command := exec.Command("git", "worktree", "remove", path, "--force")
-	return runCommand(workingDir, command)
+
+	g.worktreeMu.Lock()
+	defer g.worktreeMu.Unlock()
+	return g.runCommand(workingDir, command)
 }
 
 // Add runs 'git add' with the given args.
@@ -52,13 +146,50 @@ func (g *Git) Add(workingDir string, args ...string) error {
 	addArgs := []string{"add"}
 	addArgs = append(addArgs, args...)
 	command := exec.Command("git", addArgs...)
-	return runCommand(workingDir, command)
+	return g.runCommand(workingDir, command)
 }
 
-// Commit runs 'git commit' with the given message. the commit is signed off.
+// Commit runs 'git commit' with the given message. the commit is signed off,
+// and GPG-signed if SignCommits is set.
 func (g *Git) Commit(workingDir string, message string) error {
-	command := exec.Command("git", "commit", "--message", message, "--signoff")
-	return runCommand(workingDir, command)
+	args := []string{"commit", "--message", message, "--signoff"}
+	if g.SignCommits {
+		if g.SigningKey != "" {
+			args = append(args, "--gpg-sign="+g.SigningKey)
+		} else {
+			args = append(args, "--gpg-sign")
+		}
+	}
+	command := exec.Command("git", args...)
+	command.Env = os.Environ()
+	if name := g.identity(workingDir, "user.name", g.UserName, DefaultUserName); name != "" {
+		command.Env = append(command.Env, "GIT_AUTHOR_NAME="+name, "GIT_COMMITTER_NAME="+name)
+	}
+	if email := g.identity(workingDir, "user.email", g.UserEmail, DefaultUserEmail); email != "" {
+		command.Env = append(command.Env, "GIT_AUTHOR_EMAIL="+email, "GIT_COMMITTER_EMAIL="+email)
+	}
+	if err := g.runCommand(workingDir, command); err != nil {
+		if g.SignCommits {
+			return fmt.Errorf("could not create a signed commit, make sure GPG and the signing key are available: %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// identity returns the value to set for the given key of the commit identity:
+// the configured value, nothing if the Git configuration sets the key, or the
+// default value otherwise.
+func (g *Git) identity(workingDir string, key string, value string, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	command := exec.Command("git", "config", key)
+	command.Dir = workingDir
+	if out, err := command.Output(); err == nil && strings.TrimSpace(string(out)) != "" {
+		return ""
+	}
+	return defaultValue
 }
 
 // Push runs 'git push' with the given args.
@@ -66,24 +197,145 @@ func (g *Git) Push(workingDir string, args ...string) error {
 	pushArgs := []string{"push"}
 	pushArgs = append(pushArgs, args...)
 	command := exec.Command("git", pushArgs...)
-	return runCommand(workingDir, command)
+	return g.runCommand(workingDir, command)
+}
+
+// ChangedFiles runs 'git diff --name-only' and returns the files changed since
+// the given revision, relative to the working directory.
+func (g *Git) ChangedFiles(workingDir string, since string) ([]string, error) {
+	command := exec.Command("git", "diff", "--name-only", "--relative", since)
+	command.Dir = workingDir
+	stderr := g.Logger.Writer("git", nil)
+	defer stderr.Close()
+	command.Stderr = stderr
+	out, err := command.Output()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
 }
 
-// GetPushURL returns the push url with a token inserted
+// GetPushURL returns the push url with a token inserted. SSH remotes, e.g.
+// git@ghe.example.com:owner/repo.git, are turned into HTTPS URLs of the same
+// host, so that the token can be used to push to GitHub Enterprise Server.
+// In PushModeSSH, the SSH form of the push url is returned instead and the
+// token is not used.
 func (g *Git) GetPushURL(remote string, token string) (string, error) {
-	pushURL, err := exec.Command("git", "remote", "get-url", "--push", remote).Output()
+	pushURL, err := g.GetRemoteURL(remote)
 	if err != nil {
 		return "", err
 	}
 
-	pushURLArray := strings.SplitAfter(strings.TrimSpace(string(pushURL)), "https://")
-	pushURLWithToken := fmt.Sprintf("https://x-access-token:%s@%s", token, pushURLArray[1])
-	return pushURLWithToken, nil
+	switch g.PushMode {
+	case "", PushModeHTTPS:
+	case PushModeSSH:
+		return sshPushURL(pushURL)
+	default:
+		return "", fmt.Errorf("unknown push mode %q, must be one of: %s, %s", g.PushMode, PushModeHTTPS, PushModeSSH)
+	}
+
+	return pushURLWithToken(pushURL, g.tokenUser(), token)
+}
+
+func (g *Git) tokenUser() string {
+	if g.TokenUser == "" {
+		return "x-access-token"
+	}
+	return g.TokenUser
+}
+
+// GetRemoteURL returns the URL the given remote pushes to.
+func (g *Git) GetRemoteURL(remote string) (string, error) {
+	remoteURL, err := exec.Command("git", "remote", "get-url", "--push", remote).Output()
+	if err != nil {
+		return "", fmt.Errorf("could not get the URL of remote %q: %v", remote, err)
+	}
+	return strings.TrimSpace(string(remoteURL)), nil
+}
+
+// RepositoryFromURL returns the owner and the name of the repository a HTTPS
+// or SSH remote URL points at, e.g. "org" and "repo" for
+// git@github.com:org/repo.git. The owner of GitLab projects in subgroups
+// contains the groups separated by slashes.
+func RepositoryFromURL(remoteURL string) (string, string, error) {
+	_, path, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return "", "", err
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 || i == len(path)-1 {
+		return "", "", fmt.Errorf("remote URL %q does not point at a repository", remoteURL)
+	}
+	return path[:i], path[i+1:], nil
+}
+
+// scpLikeURL matches remotes in the scp-like syntax of Git, i.e. user@host:path.
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+func pushURLWithToken(remoteURL string, tokenUser string, token string) (string, error) {
+	host, path, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://%s:%s@%s%s", tokenUser, token, host, path), nil
+}
+
+// sshPushURL returns SSH remotes unchanged and turns HTTPS remotes into the
+// scp-like SSH URL of the same host, e.g. git@github.com:owner/repo.git.
+func sshPushURL(remoteURL string) (string, error) {
+	host, path, err := parseRemoteURL(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(remoteURL, "https://") {
+		return remoteURL, nil
+	}
+	path = strings.TrimPrefix(path, "/")
+	if !strings.HasSuffix(path, ".git") {
+		path += ".git"
+	}
+	return fmt.Sprintf("git@%s:%s", strings.Split(host, ":")[0], path), nil
+}
+
+// parseRemoteURL returns the host and the absolute path of a HTTPS or SSH
+// remote URL. The host of SSH URLs does not contain the port, which does not
+// apply to HTTPS.
+func parseRemoteURL(remoteURL string) (string, string, error) {
+	if !strings.Contains(remoteURL, "://") {
+		matches := scpLikeURL.FindStringSubmatch(remoteURL)
+		if matches == nil {
+			return "", "", fmt.Errorf("unsupported push URL %q", remoteURL)
+		}
+		return matches[1], "/" + strings.TrimPrefix(matches[2], "/"), nil
+	}
+
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "https":
+		return u.Host, u.Path, nil
+	case "ssh":
+		return u.Hostname(), u.Path, nil
+	default:
+		return "", "", fmt.Errorf("unsupported push URL %q, must be an HTTPS or SSH URL", remoteURL)
+	}
 }
 
-func runCommand(workingDir string, command *exec.Cmd) error {
+// runCommand runs the command in the working directory, logging its output.
+func (g *Git) runCommand(workingDir string, command *exec.Cmd) error {
+	out := g.Logger.Writer("git", nil)
+	defer out.Close()
 	command.Dir = workingDir
-	command.Stdout = os.Stdout
-	command.Stderr = os.Stderr
+	command.Stdout = out
+	command.Stderr = out
 	return command.Run()
 }
@@ -0,0 +1,376 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/helm/chart-releaser/pkg/github"
+	"github.com/helm/chart-releaser/pkg/version"
+)
+
+// pageSize is the number of releases requested per page
+const pageSize = 50
+
+// Client is the client for interacting with the API of a Gitea instance.
+// Releases and assets are represented with the types of the github package,
+// so that the client can be used in place of the GitHub client.
+type Client struct {
+	owner   string
+	repo    string
+	token   string
+	baseURL *url.URL
+	header  http.Header
+	client  *http.Client
+	drafts  github.DraftCache
+}
+
+// Option configures optional behavior of the Client
+type Option func(*Client)
+
+// WithHeader sets headers sent with every request, e.g. a User-Agent required
+// by a proxy.
+func WithHeader(header http.Header) Option {
+	return func(c *Client) {
+		c.header = header
+	}
+}
+
+// WithTransport sets the transport the requests are sent with, e.g. one using
+// a proxy.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.client.Transport = transport
+	}
+}
+
+// NewClient creates and initializes a new Gitea client. The base URL is the
+// API endpoint of the Gitea instance, e.g. https://gitea.example.com/api/v1/.
+func NewClient(owner, repo, token, baseURL string, opts ...Option) (*Client, error) {
+	if baseURL == "" {
+		return nil, errors.New("the base URL of the Gitea API is required, e.g. https://gitea.example.com/api/v1/")
+	}
+	endpoint, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid base URL %q", baseURL)
+	}
+	if !strings.HasSuffix(endpoint.Path, "/") {
+		endpoint.Path += "/"
+	}
+
+	c := &Client{
+		owner:   owner,
+		repo:    repo,
+		token:   token,
+		baseURL: endpoint,
+		client:  &http.Client{Timeout: time.Minute},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// release is a release of the Gitea API
+type release struct {
+	ID              int64     `json:"id"`
+	TagName         string    `json:"tag_name"`
+	TargetCommitish string    `json:"target_commitish,omitempty"`
+	Name            string    `json:"name"`
+	Body            string    `json:"body"`
+	Prerelease      bool      `json:"prerelease"`
+	Draft           bool      `json:"draft"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+	Assets          []asset   `json:"assets,omitempty"`
+}
+
+// asset is an attachment of a release of the Gitea API
+type asset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+func (r *release) toRelease() *github.Release {
+	result := &github.Release{
+		ID:          r.ID,
+		Name:        r.Name,
+		Tag:         r.TagName,
+		Description: r.Body,
+		Prerelease:  r.Prerelease,
+		Draft:       r.Draft,
+		CreatedAt:   r.CreatedAt,
+		Assets:      []*github.Asset{},
+	}
+	for _, a := range r.Assets {
+		result.Assets = append(result.Assets, &github.Asset{Path: a.Name, URL: a.BrowserDownloadURL})
+	}
+	return result
+}
+
+func (c *Client) repoPath(format string, args ...interface{}) string {
+	return fmt.Sprintf("repos/%s/%s/", url.PathEscape(c.owner), url.PathEscape(c.repo)) + fmt.Sprintf(format, args...)
+}
+
+// do sends a request to the API and decodes the JSON response into result,
+// unless it is nil.
+func (c *Client) do(ctx context.Context, method string, path string, contentType string, body io.Reader, result interface{}) error {
+	endpoint, err := c.baseURL.Parse(path)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{
+			code:    resp.StatusCode,
+			message: fmt.Sprintf("%s %s: %s %s", method, endpoint, resp.Status, strings.TrimSpace(string(message))),
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// statusError is returned for responses with a status other than 2xx.
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+func (c *Client) doJSON(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, method, path, "application/json", bytes.NewReader(data), result)
+}
+
+// GetRelease queries the Gitea API for the release of the given tag. Draft
+// releases, which are not found by their tag, are looked up in the list of
+// releases, which is fetched once.
+func (c *Client) GetRelease(ctx context.Context, tag string) (*github.Release, error) {
+	var result release
+	if err := c.do(ctx, http.MethodGet, c.repoPath("releases/tags/%s", url.PathEscape(tag)), "", nil, &result); err != nil {
+		if statusErr, ok := err.(*statusError); ok && statusErr.code == http.StatusNotFound {
+			if draft, listErr := c.drafts.Get(ctx, tag, c.ListReleases); listErr == nil && draft != nil {
+				return draft, nil
+			}
+			return nil, github.ReleaseNotFound(err)
+		}
+		return nil, err
+	}
+	return result.toRelease(), nil
+}
+
+// PublishRelease publishes a draft release
+func (c *Client) PublishRelease(ctx context.Context, release *github.Release) error {
+	err := c.doJSON(ctx, http.MethodPatch, c.repoPath("releases/%d", release.ID), map[string]bool{"draft": false}, nil)
+	if err == nil {
+		c.drafts.Remove(release.Tag)
+	}
+	return err
+}
+
+// GetCommitSHA returns the SHA of the commit the given ref, i.e. a commit SHA,
+// a branch or "tags/<tag>", points at. It returns an empty string if there is
+// no such ref.
+func (c *Client) GetCommitSHA(ctx context.Context, ref string) (string, error) {
+	var path string
+	var result struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+	if tag := strings.TrimPrefix(ref, "tags/"); tag != ref {
+		path = c.repoPath("tags/%s", url.PathEscape(tag))
+	} else {
+		path = c.repoPath("git/commits/%s", url.PathEscape(ref))
+	}
+	if err := c.do(ctx, http.MethodGet, path, "", nil, &result); err != nil {
+		if statusErr, ok := err.(*statusError); ok && statusErr.code == http.StatusNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	if result.Commit.SHA != "" {
+		return result.Commit.SHA, nil
+	}
+	return result.SHA, nil
+}
+
+// GetReleaseByID queries the Gitea API for the release with the given ID
+func (c *Client) GetReleaseByID(ctx context.Context, id int64) (*github.Release, error) {
+	var result release
+	if err := c.do(ctx, http.MethodGet, c.repoPath("releases/%d", id), "", nil, &result); err != nil {
+		return nil, err
+	}
+	return result.toRelease(), nil
+}
+
+// ListReleases queries the Gitea API for all releases of the repository
+func (c *Client) ListReleases(ctx context.Context) ([]*github.Release, error) {
+	var result []*github.Release
+	for page := 1; ; page++ {
+		var releases []release
+		if err := c.do(ctx, http.MethodGet, c.repoPath("releases?page=%d&limit=%d", page, pageSize), "", nil, &releases); err != nil {
+			return nil, err
+		}
+		for i := range releases {
+			result = append(result, releases[i].toRelease())
+		}
+		if len(releases) < pageSize {
+			return result, nil
+		}
+	}
+}
+
+// DeleteRelease deletes a release object. The tag of the release is kept.
+func (c *Client) DeleteRelease(ctx context.Context, release *github.Release) error {
+	err := c.do(ctx, http.MethodDelete, c.repoPath("releases/%d", release.ID), "", nil, nil)
+	if err == nil && release.Draft {
+		c.drafts.Remove(release.Tag)
+	}
+	return err
+}
+
+// DeleteTag deletes the given tag from the repository
+func (c *Client) DeleteTag(ctx context.Context, tag string) error {
+	return c.do(ctx, http.MethodDelete, c.repoPath("tags/%s", url.PathEscape(tag)), "", nil, nil)
+}
+
+// CreateRelease creates a new release object in the Gitea API and uploads its
+// assets
+func (c *Client) CreateRelease(ctx context.Context, input *github.Release) error {
+	body := &release{
+		TagName:         input.Tag,
+		TargetCommitish: input.Commit,
+		Name:            input.Name,
+		Body:            input.Description,
+		Prerelease:      input.Prerelease,
+		Draft:           input.Draft,
+	}
+	var result release
+	if err := c.doJSON(ctx, http.MethodPost, c.repoPath("releases"), body, &result); err != nil {
+		return err
+	}
+
+	input.ID = result.ID
+	c.drafts.Add(input)
+	return c.UploadAssets(ctx, input, input.Assets)
+}
+
+// UploadAssets uploads the given assets as attachments of an existing release
+// object
+func (c *Client) UploadAssets(ctx context.Context, release *github.Release, assets []*github.Asset) error {
+	for _, a := range assets {
+		if err := c.uploadAsset(ctx, release, a); err != nil {
+			return errors.Wrapf(err, "failed to upload release asset: %s", a.Path)
+		}
+	}
+	return nil
+}
+
+func (c *Client) uploadAsset(ctx context.Context, release *github.Release, a *github.Asset) error {
+	file, err := os.Open(a.Path)
+	if err != nil {
+		return err
+	}
+
+	// the multipart body is streamed from the file instead of being buffered
+	// in memory
+	body, pipe := io.Pipe()
+	writer := multipart.NewWriter(pipe)
+	go func() {
+		defer file.Close()
+		part, err := writer.CreateFormFile("attachment", a.FileName())
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pipe.CloseWithError(err)
+	}()
+
+	path := c.repoPath("releases/%d/assets?name=%s", release.ID, url.QueryEscape(a.FileName()))
+	err = c.do(ctx, http.MethodPost, path, writer.FormDataContentType(), body, nil)
+	// stops the writing goroutine if the request failed before the body was
+	// read to its end
+	body.Close()
+	return err
+}
+
+// CreatePullRequest creates a pull request in the repository specified by
+// owner and repo. The return value is the pull request URL.
+func (c *Client) CreatePullRequest(owner string, repo string, message string, head string, base string) (string, error) {
+	split := strings.SplitN(message, "\n", 2)
+	body := map[string]string{
+		"title": split[0],
+		"head":  head,
+		"base":  base,
+	}
+	if len(split) == 2 {
+		body["body"] = strings.TrimSpace(split[1])
+	}
+
+	var result struct {
+		HTMLURL string `json:"html_url"`
+	}
+	path := fmt.Sprintf("repos/%s/%s/pulls", url.PathEscape(owner), url.PathEscape(repo))
+	if err := c.doJSON(context.Background(), http.MethodPost, path, body, &result); err != nil {
+		return "", err
+	}
+	return result.HTMLURL, nil
+}
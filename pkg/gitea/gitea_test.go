@@ -0,0 +1,305 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/helm/chart-releaser/pkg/github"
+)
+
+// fakeGitea is an in-memory implementation of the parts of the Gitea API
+// used by the client.
+type fakeGitea struct {
+	sync.Mutex
+	releases []*release
+	uploads  map[string]string
+	pulls    []map[string]string
+	header   http.Header
+	lists    int
+}
+
+func newFakeGitea(t *testing.T) (*fakeGitea, *Client) {
+	f := &fakeGitea{uploads: map[string]string{}}
+	server := httptest.NewServer(http.StripPrefix("/api/v1", f))
+	t.Cleanup(server.Close)
+
+	c, err := NewClient("owner", "repo", "token", server.URL+"/api/v1", WithHeader(http.Header{"X-Test": {"test"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, c
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.header = r.Header
+
+	path := strings.TrimPrefix(r.URL.Path, "/repos/owner/repo/")
+	switch {
+	case r.Method == http.MethodPost && path == "releases":
+		var input release
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		input.ID = int64(len(f.releases) + 1)
+		f.releases = append(f.releases, &input)
+		json.NewEncoder(w).Encode(input)
+	case r.Method == http.MethodGet && path == "releases":
+		f.lists++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		result := []*release{}
+		for i := (page - 1) * limit; i < page*limit && i < len(f.releases); i++ {
+			result = append(result, f.releases[i])
+		}
+		json.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "releases/tags/"):
+		for _, rel := range f.releases {
+			// like Gitea, drafts are not found by their tag
+			if rel.TagName == strings.TrimPrefix(path, "releases/tags/") && !rel.Draft {
+				json.NewEncoder(w).Encode(rel)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/assets"):
+		rel := f.find(strings.TrimSuffix(strings.TrimPrefix(path, "releases/"), "/assets"))
+		file, _, err := r.FormFile("attachment")
+		if rel == nil || err != nil {
+			http.Error(w, "bad upload", http.StatusBadRequest)
+			return
+		}
+		content, _ := ioutil.ReadAll(file)
+		name := r.URL.Query().Get("name")
+		f.uploads[name] = string(content)
+		rel.Assets = append(rel.Assets, asset{ID: int64(len(rel.Assets) + 1), Name: name, BrowserDownloadURL: "https://gitea.example.com/attachments/" + name})
+		json.NewEncoder(w).Encode(rel.Assets[len(rel.Assets)-1])
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "releases/"):
+		if rel := f.find(strings.TrimPrefix(path, "releases/")); rel != nil {
+			json.NewEncoder(w).Encode(rel)
+			return
+		}
+		http.NotFound(w, r)
+	case r.Method == http.MethodPatch && strings.HasPrefix(path, "releases/"):
+		rel := f.find(strings.TrimPrefix(path, "releases/"))
+		if rel == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(rel); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(rel)
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "releases/"):
+		for i, rel := range f.releases {
+			if strconv.FormatInt(rel.ID, 10) == strings.TrimPrefix(path, "releases/") {
+				f.releases = append(f.releases[:i], f.releases[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	case r.Method == http.MethodGet && path == "tags/test-chart-0.1.0":
+		fmt.Fprint(w, `{"name": "test-chart-0.1.0", "commit": {"sha": "9c3f0e5a2b7d4e6f8a1c0b9d8e7f6a5b4c3d2e1f"}}`)
+	case r.Method == http.MethodGet && path == "git/commits/main":
+		fmt.Fprint(w, `{"sha": "0123456789abcdef0123456789abcdef01234567"}`)
+	case r.Method == http.MethodPost && path == "pulls":
+		var input map[string]string
+		json.NewDecoder(r.Body).Decode(&input)
+		f.pulls = append(f.pulls, input)
+		fmt.Fprintf(w, `{"html_url": "https://gitea.example.com/owner/repo/pulls/%d"}`, len(f.pulls))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeGitea) find(id string) *release {
+	for _, rel := range f.releases {
+		if strconv.FormatInt(rel.ID, 10) == id {
+			return rel
+		}
+	}
+	return nil
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient("owner", "repo", "token", "")
+	assert.Error(t, err)
+
+	c, err := NewClient("owner", "repo", "token", "https://gitea.example.com/api/v1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://gitea.example.com/api/v1/", c.baseURL.String())
+}
+
+func TestClient_CreateRelease(t *testing.T) {
+	f, c := newFakeGitea(t)
+
+	dir := t.TempDir()
+	chartPackage := filepath.Join(dir, "test-chart-0.1.0.tgz")
+	assert.NoError(t, ioutil.WriteFile(chartPackage, []byte("chart"), 0644))
+
+	input := &github.Release{
+		Name:        "test-chart-0.1.0",
+		Tag:         "test-chart-0.1.0",
+		Description: "A Helm chart",
+		Commit:      "main",
+		Assets:      []*github.Asset{{Path: chartPackage}},
+	}
+	assert.NoError(t, c.CreateRelease(context.TODO(), input))
+	assert.Equal(t, int64(1), input.ID)
+	assert.Equal(t, map[string]string{"test-chart-0.1.0.tgz": "chart"}, f.uploads)
+	assert.Equal(t, "token token", f.header.Get("Authorization"))
+	assert.Equal(t, "test", f.header.Get("X-Test"))
+
+	release, err := c.GetRelease(context.TODO(), "test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-chart-0.1.0", release.Name)
+	assert.Equal(t, "A Helm chart", release.Description)
+	assert.Len(t, release.Assets, 1)
+	assert.Equal(t, "https://gitea.example.com/attachments/test-chart-0.1.0.tgz", release.Assets[0].URL)
+
+	release, err = c.GetReleaseByID(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-chart-0.1.0", release.Tag)
+
+	_, err = c.GetRelease(context.TODO(), "missing-0.1.0")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+	assert.True(t, errors.Is(err, github.ErrReleaseNotFound))
+}
+
+func TestClient_UploadAssets(t *testing.T) {
+	f, c := newFakeGitea(t)
+	release := &github.Release{Name: "test-chart-0.1.0", Tag: "test-chart-0.1.0"}
+	assert.NoError(t, c.CreateRelease(context.TODO(), release))
+
+	// larger than the buffers of the multipart writer
+	dir := t.TempDir()
+	content := strings.Repeat("chart", 1<<16)
+	chartPackage := filepath.Join(dir, "test-chart-0.1.0.tgz")
+	assert.NoError(t, ioutil.WriteFile(chartPackage, []byte(content), 0644))
+	assert.NoError(t, c.UploadAssets(context.TODO(), release, []*github.Asset{{Path: chartPackage}}))
+	assert.Equal(t, content, f.uploads["test-chart-0.1.0.tgz"])
+
+	// the upload to a release which does not exist is rejected
+	err := c.UploadAssets(context.TODO(), &github.Release{ID: 42}, []*github.Asset{{Path: chartPackage}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "400")
+
+	err = c.UploadAssets(context.TODO(), release, []*github.Asset{{Path: filepath.Join(dir, "missing-0.1.0.tgz")}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to upload release asset")
+}
+
+func TestClient_ListReleases(t *testing.T) {
+	f, c := newFakeGitea(t)
+	for i := 0; i < pageSize+3; i++ {
+		f.releases = append(f.releases, &release{ID: int64(i + 1), TagName: fmt.Sprintf("chart-0.%d.0", i)})
+	}
+
+	releases, err := c.ListReleases(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, releases, pageSize+3)
+
+	assert.NoError(t, c.DeleteRelease(context.TODO(), releases[0]))
+	releases, err = c.ListReleases(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, releases, pageSize+2)
+}
+
+func TestClient_CreatePullRequest(t *testing.T) {
+	f, c := newFakeGitea(t)
+
+	url, err := c.CreatePullRequest("owner", "repo", "Update index.yaml\n\nSigned-off-by: test", "chart-releaser-abc", "gh-pages")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://gitea.example.com/owner/repo/pulls/1", url)
+	assert.Equal(t, []map[string]string{{
+		"title": "Update index.yaml",
+		"body":  "Signed-off-by: test",
+		"head":  "chart-releaser-abc",
+		"base":  "gh-pages",
+	}}, f.pulls)
+}
+
+func TestClient_GetCommitSHA(t *testing.T) {
+	_, c := newFakeGitea(t)
+
+	sha, err := c.GetCommitSHA(context.TODO(), "tags/test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "9c3f0e5a2b7d4e6f8a1c0b9d8e7f6a5b4c3d2e1f", sha)
+
+	sha, err = c.GetCommitSHA(context.TODO(), "main")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", sha)
+
+	sha, err = c.GetCommitSHA(context.TODO(), "tags/missing-0.1.0")
+	assert.NoError(t, err)
+	assert.Empty(t, sha)
+}
+
+func TestClient_PublishRelease(t *testing.T) {
+	f, c := newFakeGitea(t)
+	ctx := context.Background()
+	assert.NoError(t, c.CreateRelease(ctx, &github.Release{Name: "test-chart-0.1.0", Tag: "test-chart-0.1.0", Draft: true}))
+
+	release, err := c.GetRelease(ctx, "test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.True(t, release.Draft)
+
+	assert.NoError(t, c.PublishRelease(ctx, release))
+	assert.False(t, f.releases[0].Draft)
+	release, err = c.GetRelease(ctx, "test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.False(t, release.Draft)
+}
+
+func TestClient_GetReleaseListsDraftsOnce(t *testing.T) {
+	f, c := newFakeGitea(t)
+	ctx := context.Background()
+	assert.NoError(t, c.CreateRelease(ctx, &github.Release{Name: "test-chart-0.1.0", Tag: "test-chart-0.1.0", Draft: true}))
+
+	for _, tag := range []string{"missing-0.1.0", "other-0.1.0"} {
+		_, err := c.GetRelease(ctx, tag)
+		assert.Error(t, err)
+	}
+	release, err := c.GetRelease(ctx, "test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.True(t, release.Draft)
+	assert.Equal(t, 1, f.lists)
+
+	// drafts created after the listing are found as well
+	assert.NoError(t, c.CreateRelease(ctx, &github.Release{Name: "other-chart-0.1.0", Tag: "other-chart-0.1.0", Draft: true}))
+	release, err = c.GetRelease(ctx, "other-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.True(t, release.Draft)
+	assert.Equal(t, 1, f.lists)
+}
@@ -0,0 +1,65 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"sync"
+)
+
+// DraftCache holds the draft releases of a repository. Drafts are not found by
+// their tag, so looking one up requires listing all releases, which is done
+// once and not for every release that does not exist (yet).
+type DraftCache struct {
+	mu     sync.Mutex
+	drafts map[string]*Release
+}
+
+// Get returns the draft release of the given tag, or nil if there is none.
+// The releases are listed with list on the first call.
+func (d *DraftCache) Get(ctx context.Context, tag string, list func(context.Context) ([]*Release, error)) (*Release, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.drafts == nil {
+		releases, err := list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.drafts = make(map[string]*Release)
+		for _, release := range releases {
+			if release.Draft {
+				d.drafts[release.Tag] = release
+			}
+		}
+	}
+	return d.drafts[tag], nil
+}
+
+// Add records a draft release created after the releases were listed.
+func (d *DraftCache) Add(release *Release) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.drafts != nil && release.Draft {
+		d.drafts[release.Tag] = release
+	}
+}
+
+// Remove forgets the draft release of the given tag, e.g. once it is
+// published or deleted.
+func (d *DraftCache) Remove(tag string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.drafts, tag)
+}
@@ -16,109 +16,620 @@ package github
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/Songmu/retry"
 	"github.com/pkg/errors"
 
+	"github.com/helm/chart-releaser/pkg/logging"
+	"github.com/helm/chart-releaser/pkg/version"
+
 	"github.com/google/go-github/v33/github"
 	"golang.org/x/oauth2"
 )
 
+// DefaultBaseURL is the API endpoint of github.com
+const DefaultBaseURL = "https://api.github.com/"
+
+// DefaultUploadURL is the upload endpoint of github.com
+const DefaultUploadURL = "https://uploads.github.com/"
+
+// ErrReleaseNotFound is matched by the errors GetRelease returns if there is
+// no release of the tag, e.g. with errors.Is.
+var ErrReleaseNotFound = errors.New("release not found")
+
+// releaseNotFoundError keeps the message of the provider's error while
+// matching ErrReleaseNotFound.
+type releaseNotFoundError struct {
+	err error
+}
+
+func (e *releaseNotFoundError) Error() string { return e.err.Error() }
+
+func (e *releaseNotFoundError) Unwrap() error { return e.err }
+
+func (e *releaseNotFoundError) Is(target error) bool { return target == ErrReleaseNotFound }
+
+// ReleaseNotFound marks err, which a provider returned because there is no
+// release of the tag, as matching ErrReleaseNotFound.
+func ReleaseNotFound(err error) error {
+	return &releaseNotFoundError{err: err}
+}
+
 type Release struct {
-	Name        string
+	ID   int64
+	Name string
+	// Tag is the name of the tag the release is created for, which releases
+	// are looked up by.
+	Tag         string
 	Description string
 	Assets      []*Asset
 	Commit      string
+	Prerelease  bool
+	// Draft releases are only visible to collaborators until they are
+	// published, and their assets can not be downloaded anonymously.
+	Draft bool
+	// MakeLatest is passed as make_latest, i.e. "true", "false" or "legacy".
+	// GitHub's default applies if it is empty.
+	MakeLatest string
+	CreatedAt  time.Time
+	// GenerateReleaseNotes makes GitHub generate release notes from the
+	// commits since PreviousTag, or since the previous release if it is
+	// empty. The description is put in front of the generated notes.
+	GenerateReleaseNotes bool
+	PreviousTag          string
+	// DiscussionCategory is the name of the category of the discussion
+	// GitHub opens for the release. No discussion is opened if it is empty.
+	DiscussionCategory string
 }
 
 type Asset struct {
 	Path string
 	URL  string
+	// Name is the file name the asset is uploaded as. It defaults to the base
+	// name of Path.
+	Name string
+	// ContentType is the media type the asset is uploaded with. It defaults
+	// to one matching the file extension.
+	ContentType string
+	// Label is shown on the release page instead of the file name if set.
+	Label string
+}
+
+// FileName returns the file name the asset is uploaded as.
+func (a *Asset) FileName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return filepath.Base(a.Path)
 }
 
 // Client is the client for interacting with the GitHub API
 type Client struct {
-	owner string
-	repo  string
+	owner          string
+	repo           string
+	maxRetries     int
+	retryDelay     time.Duration
+	rateLimitPause bool
+	skipExisting   bool
+	header         http.Header
+	transport      http.RoundTripper
+	logger         *logging.Logger
+	drafts         DraftCache
 	*github.Client
 }
 
-// NewClient creates and initializes a new GitHubClient
-func NewClient(owner, repo, token, baseURL, uploadURL string) *Client {
-	var client *github.Client
+// Option configures optional behavior of the Client
+type Option func(*Client)
+
+// WithRetries sets how often failed API calls are retried and the base delay
+// of the exponential backoff between them.
+func WithRetries(maxRetries int, retryDelay time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryDelay = retryDelay
+	}
+}
+
+// WithRateLimitPause makes the client pause and resume when GitHub responds
+// with a secondary rate limit or abuse detection error.
+func WithRateLimitPause(pause bool) Option {
+	return func(c *Client) {
+		c.rateLimitPause = pause
+	}
+}
+
+// WithSkipExisting makes uploads keep assets of the same name which already
+// exist on the release instead of replacing them.
+func WithSkipExisting(skip bool) Option {
+	return func(c *Client) {
+		c.skipExisting = skip
+	}
+}
+
+// WithHeader sets headers sent with every request, e.g. a User-Agent required
+// by a proxy. They replace the headers set by the client.
+func WithHeader(header http.Header) Option {
+	return func(c *Client) {
+		c.header = header
+	}
+}
+
+// WithTransport sets the transport of the requests, e.g. one using a proxy.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithLogger sets the logger used for reporting retries
+func WithLogger(logger *logging.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// NewClient creates and initializes a new GitHubClient. The base URL defaults
+// to github.com and the upload URL to the one matching the base URL.
+func NewClient(owner, repo, token, baseURL, uploadURL string, opts ...Option) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if uploadURL == "" {
+		uploadURL = defaultUploadURL(baseURL)
+	}
+
+	c := &Client{
+		owner:      owner,
+		repo:       repo,
+		maxRetries: 3,
+		retryDelay: time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var httpClient *http.Client
+	transport := c.transport
+	if len(c.header) > 0 {
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		transport = &headerTransport{header: c.header, base: transport}
+	}
+	if transport != nil {
+		httpClient = &http.Client{Transport: transport}
+	}
 	if token != "" {
 		ts := oauth2.StaticTokenSource(&oauth2.Token{
 			AccessToken: token,
 		})
-		tc := oauth2.NewClient(context.TODO(), ts)
-		client = github.NewClient(tc)
-	} else {
-		client = github.NewClient(nil)
+		ctx := context.TODO()
+		if httpClient != nil {
+			ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+		}
+		httpClient = oauth2.NewClient(ctx, ts)
 	}
+	c.Client = github.NewClient(httpClient)
+	c.UserAgent = version.UserAgent()
 
 	if baseEndpoint, err := url.Parse(baseURL); err == nil {
 		if !strings.HasSuffix(baseEndpoint.Path, "/") {
 			baseEndpoint.Path += "/"
 		}
-		client.BaseURL = baseEndpoint
+		c.BaseURL = baseEndpoint
 	}
 
 	if uploadEndpoint, err := url.Parse(uploadURL); err == nil {
 		if !strings.HasSuffix(uploadEndpoint.Path, "/") {
 			uploadEndpoint.Path += "/"
 		}
-		client.UploadURL = uploadEndpoint
+		c.UploadURL = uploadEndpoint
 	}
+	return c
+}
 
-	return &Client{
-		owner:  owner,
-		repo:   repo,
-		Client: client,
+// headerTransport sets the given headers on every request.
+type headerTransport struct {
+	header http.Header
+	base   http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, values := range t.header {
+		req.Header[key] = values
 	}
+	return t.base.RoundTrip(req)
 }
 
 // GetRelease queries the GitHub API for a specified release object
 func (c *Client) GetRelease(ctx context.Context, tag string) (*Release, error) {
 	// Check Release whether already exists or not
-	release, _, err := c.Repositories.GetReleaseByTag(context.TODO(), c.owner, c.repo, tag)
+	var release *github.RepositoryRelease
+	err := c.retry(ctx, func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		release, resp, err = c.Repositories.GetReleaseByTag(ctx, c.owner, c.repo, tag)
+		return resp, err
+	})
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound {
+		// draft releases are not found by their tag, which they do not have
+		// until they are published
+		if draft, listErr := c.drafts.Get(ctx, tag, c.ListReleases); listErr == nil && draft != nil {
+			return draft, nil
+		}
+		return nil, ReleaseNotFound(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toRelease(release), nil
+}
+
+// PublishRelease publishes a draft release, which creates its tag.
+func (c *Client) PublishRelease(ctx context.Context, release *Release) error {
+	err := c.retry(ctx, func() (*github.Response, error) {
+		_, resp, err := c.Repositories.EditRelease(ctx, c.owner, c.repo, release.ID, &github.RepositoryRelease{Draft: github.Bool(false)})
+		return resp, err
+	})
+	if err == nil {
+		c.drafts.Remove(release.Tag)
+	}
+	return err
+}
+
+// CheckPermissions verifies with a single cheap call that the token can read
+// the repository and create releases in it, so that a token lacking
+// permissions, e.g. a fine-grained personal access token, fails before
+// anything is released instead of with a 403 halfway through.
+func (c *Client) CheckPermissions(ctx context.Context) error {
+	var repository *github.Repository
+	err := c.retry(ctx, func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		repository, resp, err = c.Repositories.Get(ctx, c.owner, c.repo)
+		return resp, err
+	})
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil {
+		switch errResp.Response.StatusCode {
+		case http.StatusUnauthorized:
+			return errors.Errorf("the token is not valid for %s/%s", c.owner, c.repo)
+		case http.StatusForbidden, http.StatusNotFound:
+			// GitHub hides repositories the token can not read
+			return errors.Errorf("the token can not read repository %s/%s, it needs the %s",
+				c.owner, c.repo, missingPermission(errResp.Response, "metadata=read"))
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error checking the permissions of the token for %s/%s", c.owner, c.repo)
+	}
+	// tokens of GitHub Apps get no permissions reported
+	if repository.Permissions != nil && !(*repository.Permissions)["push"] {
+		return errors.Errorf("the token can not create releases in %s/%s, it needs the contents=write permission", c.owner, c.repo)
+	}
+	return nil
+}
+
+// missingPermission returns the permission GitHub names as required for the
+// rejected call, or the OAuth scope of classic tokens, falling back to the
+// given permission.
+func missingPermission(resp *http.Response, fallback string) string {
+	if permission := resp.Header.Get("X-Accepted-GitHub-Permissions"); permission != "" {
+		return permission + " permission"
+	}
+	if scopes := resp.Header.Get("X-Accepted-OAuth-Scopes"); scopes != "" {
+		return scopes + " scope"
+	}
+	return fallback + " permission"
+}
+
+// GetCommitSHA returns the SHA of the commit the given ref, i.e. a commit SHA,
+// a branch or "tags/<tag>", points at. It returns an empty string if there is
+// no such ref.
+func (c *Client) GetCommitSHA(ctx context.Context, ref string) (string, error) {
+	var sha string
+	err := c.retry(ctx, func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		sha, resp, err = c.Repositories.GetCommitSHA1(ctx, c.owner, c.repo, ref, "")
+		// unknown commit SHAs are reported as unprocessable
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity) {
+			sha = ""
+			return resp, nil
+		}
+		return resp, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+// GetReleaseByID queries the GitHub API for the release object with the
+// given ID
+func (c *Client) GetReleaseByID(ctx context.Context, id int64) (*Release, error) {
+	var release *github.RepositoryRelease
+	err := c.retry(ctx, func() (*github.Response, error) {
+		var resp *github.Response
+		var err error
+		release, resp, err = c.Repositories.GetRelease(ctx, c.owner, c.repo, id)
+		return resp, err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return toRelease(release), nil
+}
+
+// ListReleases queries the GitHub API for all releases of the repository,
+// following the pagination of the API
+func (c *Client) ListReleases(ctx context.Context) ([]*Release, error) {
+	var result []*Release
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var releases []*github.RepositoryRelease
+		var resp *github.Response
+		err := c.retry(ctx, func() (*github.Response, error) {
+			var err error
+			releases, resp, err = c.Repositories.ListReleases(ctx, c.owner, c.repo, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, release := range releases {
+			result = append(result, toRelease(release))
+		}
+		if resp.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// DeleteRelease deletes a release object including its assets. The tag of
+// the release is kept.
+func (c *Client) DeleteRelease(ctx context.Context, release *Release) error {
+	err := c.retry(ctx, func() (*github.Response, error) {
+		return c.Repositories.DeleteRelease(ctx, c.owner, c.repo, release.ID)
+	})
+	if err == nil && release.Draft {
+		c.drafts.Remove(release.Tag)
+	}
+	return err
+}
+
+// DeleteTag deletes the given tag from the repository
+func (c *Client) DeleteTag(ctx context.Context, tag string) error {
+	return c.retry(ctx, func() (*github.Response, error) {
+		return c.Git.DeleteRef(ctx, c.owner, c.repo, "tags/"+tag)
+	})
+}
+
+// defaultUploadURL returns the upload endpoint matching the given API
+// endpoint. GitHub Enterprise Server serves uploads at api/uploads/ next to
+// the api/v3/ API endpoint.
+func defaultUploadURL(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" || u.Host == "api.github.com" {
+		return DefaultUploadURL
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v3") + "/api/uploads/"
+	return u.String()
+}
 
+func toRelease(release *github.RepositoryRelease) *Release {
 	result := &Release{
-		Assets: []*Asset{},
+		ID:         release.GetID(),
+		Name:       release.GetName(),
+		Tag:        release.GetTagName(),
+		Assets:     []*Asset{},
+		Prerelease: release.GetPrerelease(),
+		Draft:      release.GetDraft(),
+		CreatedAt:  release.GetCreatedAt().Time,
 	}
 	for _, ass := range release.Assets {
-		asset := &Asset{*ass.Name, *ass.BrowserDownloadURL}
+		asset := &Asset{
+			Path:        ass.GetName(),
+			URL:         ass.GetBrowserDownloadURL(),
+			ContentType: ass.GetContentType(),
+			Label:       ass.GetLabel(),
+		}
 		result.Assets = append(result.Assets, asset)
 	}
-	return result, nil
+	return result
+}
+
+// createReleaseRequest adds the parameters which go-github does not support
+// yet to the release request.
+type createReleaseRequest struct {
+	*github.RepositoryRelease
+	MakeLatest             *string `json:"make_latest,omitempty"`
+	GenerateReleaseNotes   *bool   `json:"generate_release_notes,omitempty"`
+	DiscussionCategoryName *string `json:"discussion_category_name,omitempty"`
+}
+
+// generateNotesRequest and generateNotesResponse are the request and response
+// of the API generating release notes, which go-github does not support yet.
+type generateNotesRequest struct {
+	TagName         string `json:"tag_name"`
+	TargetCommitish string `json:"target_commitish,omitempty"`
+	PreviousTagName string `json:"previous_tag_name,omitempty"`
+}
+
+type generateNotesResponse struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
 }
 
 // CreateRelease creates a new release object in the GitHub API
 func (c *Client) CreateRelease(ctx context.Context, input *Release) error {
-	req := &github.RepositoryRelease{
-		Name:            &input.Name,
-		Body:            &input.Description,
-		TagName:         &input.Name,
-		TargetCommitish: &input.Commit,
+	body := &createReleaseRequest{
+		RepositoryRelease: &github.RepositoryRelease{
+			Name:            &input.Name,
+			Body:            &input.Description,
+			TagName:         &input.Tag,
+			TargetCommitish: &input.Commit,
+			Prerelease:      &input.Prerelease,
+			Draft:           &input.Draft,
+		},
+	}
+	if input.MakeLatest != "" {
+		body.MakeLatest = &input.MakeLatest
+	}
+	if input.DiscussionCategory != "" {
+		body.DiscussionCategoryName = &input.DiscussionCategory
+	}
+	if input.GenerateReleaseNotes {
+		if input.PreviousTag == "" {
+			// GitHub puts the body in front of the generated notes
+			body.GenerateReleaseNotes = &input.GenerateReleaseNotes
+		} else {
+			notes, err := c.generateReleaseNotes(ctx, input)
+			if err != nil {
+				return errors.Wrap(err, "failed to generate release notes")
+			}
+			description := strings.TrimSpace(input.Description + "\n\n" + notes)
+			body.Body = &description
+		}
 	}
 
-	release, _, err := c.Repositories.CreateRelease(context.TODO(), c.owner, c.repo, req)
+	release := new(github.RepositoryRelease)
+	attempted := false
+	err := c.retry(ctx, func() (*github.Response, error) {
+		if attempted {
+			// Creating a release is not idempotent. The failed attempt may
+			// have created it nevertheless, e.g. if the response timed out.
+			created, resp, err := c.findCreatedRelease(ctx, input)
+			if err != nil {
+				return resp, err
+			}
+			if created != nil {
+				release = created
+				return resp, nil
+			}
+		}
+		attempted = true
+		req, err := c.NewRequest(http.MethodPost, fmt.Sprintf("repos/%s/%s/releases", c.owner, c.repo), body)
+		if err != nil {
+			return nil, err
+		}
+		return c.Do(ctx, req, release)
+	})
 	if err != nil {
 		return err
 	}
 
-	for _, asset := range input.Assets {
-		if err := c.uploadReleaseAsset(context.TODO(), *release.ID, asset.Path); err != nil {
-			return err
+	input.ID = release.GetID()
+	c.drafts.Add(input)
+	return c.UploadAssets(ctx, input, input.Assets)
+}
+
+// findCreatedRelease looks up the release of the tag of input, which a failed
+// attempt to create it may have created. It returns nil if there is none.
+func (c *Client) findCreatedRelease(ctx context.Context, input *Release) (*github.RepositoryRelease, *github.Response, error) {
+	if input.Draft {
+		// draft releases are not found by their tag
+		opts := &github.ListOptions{PerPage: 100}
+		for {
+			releases, resp, err := c.Repositories.ListReleases(ctx, c.owner, c.repo, opts)
+			if err != nil {
+				return nil, resp, err
+			}
+			for _, release := range releases {
+				if release.GetDraft() && release.GetTagName() == input.Tag {
+					return release, resp, nil
+				}
+			}
+			if resp.NextPage == 0 {
+				return nil, resp, nil
+			}
+			opts.Page = resp.NextPage
 		}
 	}
+	release, resp, err := c.Repositories.GetReleaseByTag(ctx, c.owner, c.repo, input.Tag)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, resp, nil
+	}
+	return release, resp, err
+}
+
+// generateReleaseNotes returns the release notes GitHub generates from the
+// commits between the previous tag and the one of the release.
+func (c *Client) generateReleaseNotes(ctx context.Context, input *Release) (string, error) {
+	body := &generateNotesRequest{
+		TagName:         input.Tag,
+		TargetCommitish: input.Commit,
+		PreviousTagName: input.PreviousTag,
+	}
+	notes := new(generateNotesResponse)
+	err := c.retry(ctx, func() (*github.Response, error) {
+		req, err := c.NewRequest(http.MethodPost, fmt.Sprintf("repos/%s/%s/releases/generate-notes", c.owner, c.repo), body)
+		if err != nil {
+			return nil, err
+		}
+		return c.Do(ctx, req, notes)
+	})
+	if err != nil {
+		return "", err
+	}
+	return notes.Body, nil
+}
+
+// UploadError reports the assets of a release which were uploaded and the ones
+// which failed to upload.
+type UploadError struct {
+	Tag      string
+	Uploaded []string
+	Failed   map[string]error
+}
+
+func (e *UploadError) Error() string {
+	failed := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for i, name := range failed {
+		failed[i] = fmt.Sprintf("%s (%s)", name, e.Failed[name])
+	}
+	uploaded := "none"
+	if len(e.Uploaded) > 0 {
+		uploaded = strings.Join(e.Uploaded, ", ")
+	}
+	return fmt.Sprintf("failed to upload assets of release %s: %s, uploaded: %s", e.Tag, strings.Join(failed, ", "), uploaded)
+}
+
+// UploadAssets uploads the given assets to an existing release object. All
+// assets are attempted, if some of them fail an *UploadError is returned.
+func (c *Client) UploadAssets(ctx context.Context, release *Release, assets []*Asset) error {
+	uploadErr := &UploadError{Tag: release.Tag, Failed: map[string]error{}}
+	for _, asset := range assets {
+		name := asset.FileName()
+		if err := c.uploadReleaseAsset(ctx, release.ID, asset); err != nil {
+			c.logger.Event("upload-asset-failed", logging.Fields{"tag": release.Tag, "asset": name, "error": err.Error()},
+				"Failed to upload %s to release %s: %s", name, release.Tag, err)
+			uploadErr.Failed[name] = err
+			continue
+		}
+		uploadErr.Uploaded = append(uploadErr.Uploaded, name)
+	}
+	if len(uploadErr.Failed) > 0 {
+		return uploadErr
+	}
 	return nil
 }
 
@@ -145,32 +656,208 @@ func (c *Client) CreatePullRequest(owner string, repo string, message string, he
 	return *pullRequest.HTMLURL, nil
 }
 
+// assetContentType returns the content type the asset is uploaded with. If it
+// is empty, the content type is derived from the file extension by go-github.
+func assetContentType(asset *Asset) string {
+	if asset.ContentType != "" {
+		return asset.ContentType
+	}
+	switch filepath.Ext(asset.Path) {
+	case ".tgz":
+		return "application/gzip"
+	case ".prov":
+		return "application/pgp-signature"
+	case ".bundle":
+		return "application/json"
+	}
+	return ""
+}
+
 // UploadAsset uploads specified assets to a given release object
-func (c *Client) uploadReleaseAsset(ctx context.Context, releaseID int64, filename string) error {
+func (c *Client) uploadReleaseAsset(ctx context.Context, releaseID int64, asset *Asset) error {
 
-	filename, err := filepath.Abs(filename)
+	filename, err := filepath.Abs(asset.Path)
 	if err != nil {
 		return errors.Wrap(err, "failed to get abs path")
 	}
 
 	opts := &github.UploadOptions{
-		// Use base name by default
-		Name: filepath.Base(filename),
+		Name:      asset.FileName(),
+		Label:     asset.Label,
+		MediaType: assetContentType(asset),
 	}
 
-	if err := retry.Retry(3, 3*time.Second, func() error {
-		f, err := os.Open(filename)
+	// The file is opened once, a missing or unreadable file is not worth
+	// retrying.
+	f, err := os.Open(filename)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer f.Close()
+
+	attempted := false
+	return c.retry(ctx, func() (*github.Response, error) {
+		if attempted {
+			// A failed upload can leave a partial asset behind, which would
+			// make all further attempts fail because its name is taken.
+			existing, resp, err := c.removeExistingAsset(ctx, releaseID, opts.Name)
+			if err != nil {
+				return resp, err
+			}
+			if existing {
+				c.logger.Printf("Keeping existing asset %s", opts.Name)
+				return nil, nil
+			}
+		}
+		attempted = true
+
+		resp, err := c.streamReleaseAsset(ctx, releaseID, opts, f)
+		if err != nil {
+			return resp, errors.Wrapf(err, "failed to upload release asset: %s\n", filename)
+		}
+		return resp, nil
+	})
+}
+
+// streamReleaseAsset uploads the file as asset of the release, streaming it
+// from disk instead of reading it into memory, so that large chart packages can
+// be uploaded. The progress of large uploads is logged.
+func (c *Client) streamReleaseAsset(ctx context.Context, releaseID int64, opts *github.UploadOptions, f *os.File) (*github.Response, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"name": {opts.Name}}
+	if opts.Label != "" {
+		query.Set("label", opts.Label)
+	}
+	u := fmt.Sprintf("repos/%s/%s/releases/%d/assets?%s", c.owner, c.repo, releaseID, query.Encode())
+	mediaType := opts.MediaType
+	if mediaType == "" {
+		mediaType = mime.TypeByExtension(filepath.Ext(f.Name()))
+	}
+	// Every attempt reads the file from the start, and the section reader
+	// keeps the file from being closed by the transport.
+	body := io.NewSectionReader(f, 0, info.Size())
+	req, err := c.NewUploadRequest(u, newProgressReader(body, c.logger, opts.Name, info.Size()), info.Size(), mediaType)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(ctx, req, new(github.ReleaseAsset))
+}
+
+// removeExistingAsset deletes the asset with the given name from the release.
+// Completely uploaded assets are kept if skip-existing is set, which is
+// reported by returning true.
+func (c *Client) removeExistingAsset(ctx context.Context, releaseID int64, name string) (bool, *github.Response, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		assets, resp, err := c.Repositories.ListReleaseAssets(ctx, c.owner, c.repo, releaseID, opts)
 		if err != nil {
-			return errors.Wrap(err, "failed to open file")
+			return false, resp, err
 		}
-		defer f.Close()
-		if _, _, err = c.Repositories.UploadReleaseAsset(context.TODO(), c.owner, c.repo, releaseID, opts, f); err != nil {
-			return errors.Wrapf(err, "failed to upload release asset: %s\n", filename)
+		for _, asset := range assets {
+			if asset.GetName() != name {
+				continue
+			}
+			if c.skipExisting && asset.GetState() == "uploaded" {
+				return true, resp, nil
+			}
+			c.logger.Printf("Deleting existing asset %s before uploading it again", name)
+			resp, err := c.Repositories.DeleteReleaseAsset(ctx, c.owner, c.repo, asset.GetID())
+			return false, resp, err
 		}
-		return nil
-	}); err != nil {
-		return err
+		if resp.NextPage == 0 {
+			return false, resp, nil
+		}
+		opts.Page = resp.NextPage
 	}
+}
 
-	return nil
+// retry calls fn until it succeeds, returns an error that is not worth
+// retrying, or the maximum number of retries is reached. The delay between
+// attempts grows exponentially and honors the Retry-After header.
+func (c *Client) retry(ctx context.Context, fn func() (*github.Response, error)) error {
+	for attempt := 0; ; {
+		resp, err := fn()
+		if err == nil {
+			return nil
+		}
+
+		var delay time.Duration
+		if c.rateLimitPause && isSecondaryRateLimit(resp, err) {
+			// Pausing for a rate limit does not count as retry, as the call
+			// is expected to succeed once the advised time has passed.
+			delay = secondaryRateLimitDelay(resp, err)
+			c.logger.Event("rate-limit-pause", logging.Fields{"delay_ms": delay.Milliseconds()},
+				"Hit GitHub secondary rate limit, pausing for %s", delay)
+		} else {
+			if attempt >= c.maxRetries || !isRetryable(resp) {
+				return err
+			}
+			delay = c.backoff(attempt, resp)
+			attempt++
+			c.logger.Event("retry", logging.Fields{"attempt": attempt, "delay_ms": delay.Milliseconds(), "error": err.Error()},
+				"GitHub API call failed (%s), retrying in %s", err, delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isSecondaryRateLimit reports whether the call was rejected by GitHub's
+// secondary rate limits or abuse detection, which respond with 403.
+func isSecondaryRateLimit(resp *github.Response, err error) bool {
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		return true
+	}
+	if resp == nil || resp.Response == nil || resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "secondary rate limit") || strings.Contains(msg, "abuse detection")
+}
+
+// secondaryRateLimitDelay returns how long GitHub asks to wait before the next
+// call, falling back to one minute as recommended by the GitHub docs.
+func secondaryRateLimitDelay(resp *github.Response, err error) time.Duration {
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) && abuseErr.RetryAfter != nil {
+		return *abuseErr.RetryAfter
+	}
+	if resp != nil && resp.Response != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return time.Minute
+}
+
+// isRetryable reports whether a call that failed with the given response is
+// worth retrying. Network errors come without a response and are retried, as
+// are server errors and rate limiting. Other client errors like validation
+// failures (422) will not go away by trying again.
+func isRetryable(resp *github.Response) bool {
+	if resp == nil || resp.Response == nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+func (c *Client) backoff(attempt int, resp *github.Response) time.Duration {
+	if resp != nil && resp.Response != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	delay := c.retryDelay << uint(attempt)
+	if c.retryDelay > 0 {
+		delay += time.Duration(rand.Int63n(int64(c.retryDelay)))
+	}
+	return delay
 }
@@ -0,0 +1,998 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v33/github"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+	"github.com/helm/chart-releaser/pkg/version"
+)
+
+// newTestClient returns a Client talking to a test server which responds with
+// the given status codes, one per request, and 200 once they are used up.
+func newTestClient(t *testing.T, maxRetries int, statusCodes ...int) (*Client, *int32) {
+	return newTestClientWithOptions(t, []Option{WithRetries(maxRetries, time.Millisecond)}, statusCodes...)
+}
+
+func newTestClientWithOptions(t *testing.T, opts []Option, statusCodes ...int) (*Client, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if int(n) <= len(statusCodes) {
+			switch statusCodes[n-1] {
+			case http.StatusTooManyRequests:
+				w.Header().Set("Retry-After", "0")
+			case http.StatusForbidden:
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"message": "You have exceeded a secondary rate limit."}`)
+				return
+			}
+			w.WriteHeader(statusCodes[n-1])
+			fmt.Fprint(w, `{"message": "failed"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id": 1, "tag_name": "test-chart-0.1.0", "assets": []}`)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL, opts...)
+	return client, &requests
+}
+
+func TestClient_GetCommitSHA(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/commits/tags/test-chart-0.1.0", "/repos/owner/repo/commits/main":
+			fmt.Fprint(w, "9c3f0e5a2b7d4e6f8a1c0b9d8e7f6a5b4c3d2e1f")
+		case "/repos/owner/repo/commits/0123456":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "No commit found for SHA: 0123456"}`)
+		case "/repos/owner/repo/commits/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient("owner", "repo", "", server.URL, server.URL, WithRetries(0, time.Millisecond))
+
+	tests := []struct {
+		ref   string
+		sha   string
+		error bool
+	}{
+		{"tags/test-chart-0.1.0", "9c3f0e5a2b7d4e6f8a1c0b9d8e7f6a5b4c3d2e1f", false},
+		{"main", "9c3f0e5a2b7d4e6f8a1c0b9d8e7f6a5b4c3d2e1f", false},
+		{"tags/other-chart-0.1.0", "", false},
+		{"0123456", "", false},
+		{"broken", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			sha, err := client.GetCommitSHA(context.Background(), tt.ref)
+			if tt.error {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.sha, sha)
+		})
+	}
+}
+
+func TestClient_GetReleaseRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxRetries  int
+		statusCodes []int
+		requests    int32
+		error       bool
+	}{
+		{
+			"success",
+			3,
+			nil,
+			1,
+			false,
+		},
+		{
+			"server-errors-are-retried",
+			3,
+			[]int{http.StatusBadGateway, http.StatusServiceUnavailable},
+			3,
+			false,
+		},
+		{
+			"rate-limit-is-retried",
+			3,
+			[]int{http.StatusTooManyRequests},
+			2,
+			false,
+		},
+		{
+			"retries-exhausted",
+			2,
+			[]int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			3,
+			true,
+		},
+		{
+			"validation-error-is-not-retried",
+			3,
+			[]int{http.StatusUnprocessableEntity},
+			1,
+			true,
+		},
+		{
+			// the tag is requested once, followed by the lookup of draft
+			// releases
+			"not-found-is-not-retried",
+			3,
+			[]int{http.StatusNotFound},
+			2,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := newTestClient(t, tt.maxRetries, tt.statusCodes...)
+			release, err := client.GetRelease(context.Background(), "test-chart-0.1.0")
+			if tt.error {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), release.ID)
+			}
+			assert.Equal(t, tt.requests, atomic.LoadInt32(requests))
+		})
+	}
+}
+
+func TestClient_GetReleaseRateLimitPause(t *testing.T) {
+	tests := []struct {
+		name     string
+		pause    bool
+		requests int32
+		error    bool
+	}{
+		{
+			"pause-disabled",
+			false,
+			1,
+			true,
+		},
+		{
+			"pause-enabled",
+			true,
+			4,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// pauses do not count as retries, so they succeed even without retries
+			opts := []Option{WithRetries(0, time.Millisecond), WithRateLimitPause(tt.pause)}
+			client, requests := newTestClientWithOptions(t, opts, http.StatusForbidden, http.StatusForbidden, http.StatusForbidden)
+			_, err := client.GetRelease(context.Background(), "test-chart-0.1.0")
+			if tt.error {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.requests, atomic.LoadInt32(requests))
+		})
+	}
+}
+
+func TestClient_CheckPermissions(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		body   string
+		error  string
+	}{
+		{
+			name:   "can-push",
+			status: http.StatusOK,
+			body:   `{"name": "repo", "permissions": {"pull": true, "push": true}}`,
+		},
+		{
+			name:   "no-permissions-reported",
+			status: http.StatusOK,
+			body:   `{"name": "repo"}`,
+		},
+		{
+			name:   "read-only",
+			status: http.StatusOK,
+			body:   `{"name": "repo", "permissions": {"pull": true, "push": false}}`,
+			error:  "the token can not create releases in owner/repo, it needs the contents=write permission",
+		},
+		{
+			name:   "forbidden",
+			status: http.StatusForbidden,
+			header: map[string]string{"X-Accepted-GitHub-Permissions": "metadata=read"},
+			body:   `{"message": "Resource not accessible by personal access token"}`,
+			error:  "the token can not read repository owner/repo, it needs the metadata=read permission",
+		},
+		{
+			name:   "forbidden-classic-token",
+			status: http.StatusForbidden,
+			header: map[string]string{"X-Accepted-OAuth-Scopes": "repo"},
+			body:   `{"message": "Forbidden"}`,
+			error:  "the token can not read repository owner/repo, it needs the repo scope",
+		},
+		{
+			name:   "not-found",
+			status: http.StatusNotFound,
+			body:   `{"message": "Not Found"}`,
+			error:  "the token can not read repository owner/repo, it needs the metadata=read permission",
+		},
+		{
+			name:   "unauthorized",
+			status: http.StatusUnauthorized,
+			body:   `{"message": "Bad credentials"}`,
+			error:  "the token is not valid for owner/repo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				assert.Equal(t, "/repos/owner/repo", r.URL.Path)
+				for key, value := range tt.header {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewClient("owner", "repo", "", server.URL, server.URL, WithRetries(3, time.Millisecond))
+			err := client.CheckPermissions(context.Background())
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestClient_GetReleaseByID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/owner/repo/releases/42", r.URL.Path)
+		fmt.Fprint(w, `{"id": 42, "tag_name": "test-chart-0.1.0", "name": "Test Chart 0.1.0"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL)
+	release, err := client.GetReleaseByID(context.Background(), 42)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), release.ID)
+	assert.Equal(t, "test-chart-0.1.0", release.Tag)
+	assert.Equal(t, "Test Chart 0.1.0", release.Name)
+}
+
+func TestClient_ListReleases(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/owner/repo/releases?page=2&per_page=100>; rel="next"`, server.URL))
+			fmt.Fprint(w, `[{"id": 1, "tag_name": "test-chart-0.1.0"}, {"id": 2, "tag_name": "test-chart-0.2.0"}]`)
+		case "2":
+			fmt.Fprint(w, `[{"id": 3, "tag_name": "test-chart-0.3.0"}]`)
+		default:
+			t.Errorf("unexpected page %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL)
+	releases, err := client.ListReleases(context.Background())
+	assert.NoError(t, err)
+	var tags []string
+	for _, release := range releases {
+		tags = append(tags, release.Tag)
+	}
+	assert.Equal(t, []string{"test-chart-0.1.0", "test-chart-0.2.0", "test-chart-0.3.0"}, tags)
+}
+
+func TestClient_backoff(t *testing.T) {
+	c := &Client{retryDelay: 100 * time.Millisecond}
+
+	for attempt := 0; attempt < 4; attempt++ {
+		delay := c.backoff(attempt, nil)
+		min := c.retryDelay << uint(attempt)
+		assert.True(t, delay >= min && delay < min+c.retryDelay, "attempt %d: %s", attempt, delay)
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"7"}}}
+	assert.Equal(t, 7*time.Second, c.backoff(0, &github.Response{Response: resp}))
+}
+
+func TestClient_GetReleaseListsDraftsOnce(t *testing.T) {
+	var lists int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/repos/owner/repo/releases/tags/"):
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		case r.URL.Path == "/repos/owner/repo/releases" && r.Method == http.MethodGet:
+			atomic.AddInt32(&lists, 1)
+			fmt.Fprint(w, `[{"id": 1, "tag_name": "test-chart-0.1.0", "draft": true}]`)
+		case r.URL.Path == "/repos/owner/repo/releases" && r.Method == http.MethodPost:
+			fmt.Fprint(w, `{"id": 2, "tag_name": "other-chart-0.1.0", "draft": true}`)
+		case r.URL.Path == "/repos/owner/repo/releases/1" && r.Method == http.MethodPatch:
+			fmt.Fprint(w, `{"id": 1, "tag_name": "test-chart-0.1.0"}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient("owner", "repo", "", server.URL, server.URL)
+	for _, tag := range []string{"missing-0.1.0", "other-0.1.0"} {
+		_, err := client.GetRelease(ctx, tag)
+		assert.Error(t, err)
+	}
+	release, err := client.GetRelease(ctx, "test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), release.ID)
+
+	// drafts created after the listing are found, published ones are not
+	assert.NoError(t, client.CreateRelease(ctx, &Release{Name: "other-chart-0.1.0", Tag: "other-chart-0.1.0", Draft: true}))
+	release, err = client.GetRelease(ctx, "other-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), release.ID)
+	assert.NoError(t, client.PublishRelease(ctx, &Release{ID: 1, Tag: "test-chart-0.1.0", Draft: true}))
+	_, err = client.GetRelease(ctx, "test-chart-0.1.0")
+	assert.True(t, errors.Is(err, ErrReleaseNotFound))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lists))
+}
+
+func TestClient_CreateReleasePrerelease(t *testing.T) {
+	tests := []struct {
+		name       string
+		prerelease bool
+	}{
+		{
+			"release",
+			false,
+		},
+		{
+			"prerelease",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent github.RepositoryRelease
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+				fmt.Fprint(w, `{"id": 1}`)
+			}))
+			defer server.Close()
+
+			client := NewClient("owner", "repo", "", server.URL, server.URL)
+			err := client.CreateRelease(context.Background(), &Release{Name: "test-chart-1.0.0", Tag: "test-chart-1.0.0", Prerelease: tt.prerelease})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.prerelease, sent.GetPrerelease())
+		})
+	}
+}
+
+func TestClient_CreateReleaseDraft(t *testing.T) {
+	var sent github.RepositoryRelease
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+		fmt.Fprint(w, `{"id": 1}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL)
+	err := client.CreateRelease(context.Background(), &Release{Name: "test-chart-1.0.0", Tag: "test-chart-1.0.0", Draft: true})
+	assert.NoError(t, err)
+	assert.True(t, sent.GetDraft())
+}
+
+func TestClient_CreateReleaseRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		draft   bool
+		created bool
+		posts   int32
+	}{
+		{
+			name:    "created-by-failed-attempt",
+			created: true,
+			posts:   1,
+		},
+		{
+			name:  "not-created-by-failed-attempt",
+			posts: 2,
+		},
+		{
+			name:    "draft-created-by-failed-attempt",
+			draft:   true,
+			created: true,
+			posts:   1,
+		},
+		{
+			name:  "draft-not-created-by-failed-attempt",
+			draft: true,
+			posts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/releases"):
+					if atomic.AddInt32(&posts, 1) == 1 {
+						// the release is created, but the response fails
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					fmt.Fprint(w, `{"id": 2}`)
+				case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/releases/tags/test-chart-1.0.0"):
+					if !tt.created || tt.draft {
+						w.WriteHeader(http.StatusNotFound)
+						return
+					}
+					fmt.Fprint(w, `{"id": 1, "tag_name": "test-chart-1.0.0"}`)
+				case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/releases"):
+					if !tt.created || !tt.draft {
+						fmt.Fprint(w, `[]`)
+						return
+					}
+					fmt.Fprint(w, `[{"id": 1, "tag_name": "test-chart-1.0.0", "draft": true}]`)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+			}))
+			defer server.Close()
+
+			client := NewClient("owner", "repo", "", server.URL, server.URL, WithRetries(1, time.Millisecond))
+			release := &Release{Name: "test-chart-1.0.0", Tag: "test-chart-1.0.0", Draft: tt.draft}
+			assert.NoError(t, client.CreateRelease(context.Background(), release))
+			assert.Equal(t, tt.posts, atomic.LoadInt32(&posts))
+			if tt.created {
+				assert.Equal(t, int64(1), release.ID)
+			} else {
+				assert.Equal(t, int64(2), release.ID)
+			}
+		})
+	}
+}
+
+func TestClient_PublishDraftRelease(t *testing.T) {
+	var published github.RepositoryRelease
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/repos/owner/repo/releases/tags/"):
+			// drafts are not found by their tag
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		case r.URL.Path == "/repos/owner/repo/releases" && r.Method == http.MethodGet:
+			fmt.Fprint(w, `[{"id": 1, "tag_name": "other-chart-0.1.0", "draft": true}, {"id": 2, "tag_name": "test-chart-0.1.0", "draft": true}]`)
+		case r.URL.Path == "/repos/owner/repo/releases/2" && r.Method == http.MethodPatch:
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&published))
+			fmt.Fprint(w, `{"id": 2, "tag_name": "test-chart-0.1.0"}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL)
+	release, err := client.GetRelease(context.Background(), "test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), release.ID)
+	assert.True(t, release.Draft)
+
+	assert.NoError(t, client.PublishRelease(context.Background(), release))
+	assert.False(t, published.GetDraft())
+	assert.NotNil(t, published.Draft)
+
+	_, err = client.GetRelease(context.Background(), "missing-0.1.0")
+	assert.Error(t, err)
+}
+
+func TestClient_CreateReleaseMakeLatest(t *testing.T) {
+	tests := []struct {
+		name       string
+		makeLatest string
+		expected   interface{}
+	}{
+		{
+			"default",
+			"",
+			nil,
+		},
+		{
+			"false",
+			"false",
+			"false",
+		},
+		{
+			"legacy",
+			"legacy",
+			"legacy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+				fmt.Fprint(w, `{"id": 1}`)
+			}))
+			defer server.Close()
+
+			client := NewClient("owner", "repo", "", server.URL, server.URL)
+			err := client.CreateRelease(context.Background(), &Release{Name: "test-chart-1.0.0", Tag: "test-chart-1.0.0", MakeLatest: tt.makeLatest})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, sent["make_latest"])
+			assert.Equal(t, "test-chart-1.0.0", sent["tag_name"])
+		})
+	}
+}
+
+func TestClient_CreateReleaseDiscussionCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		category string
+		expected interface{}
+	}{
+		{"no-discussion", "", nil},
+		{"discussion", "Announcements", "Announcements"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+				fmt.Fprint(w, `{"id": 1}`)
+			}))
+			defer server.Close()
+
+			client := NewClient("owner", "repo", "", server.URL, server.URL)
+			err := client.CreateRelease(context.Background(), &Release{Name: "test-chart-1.0.0", Tag: "test-chart-1.0.0", DiscussionCategory: tt.category})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, sent["discussion_category_name"])
+		})
+	}
+}
+
+func TestClient_CreateReleaseWithTag(t *testing.T) {
+	var sent github.RepositoryRelease
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+		fmt.Fprint(w, `{"id": 1}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL)
+	err := client.CreateRelease(context.Background(), &Release{Name: "Redis 1.2.3", Tag: "redis-1.2.3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Redis 1.2.3", sent.GetName())
+	assert.Equal(t, "redis-1.2.3", sent.GetTagName())
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		baseURL   string
+		uploadURL string
+		expected  [2]string
+	}{
+		{
+			"default",
+			"",
+			"",
+			[2]string{"https://api.github.com/", "https://uploads.github.com/"},
+		},
+		{
+			"github-com",
+			"https://api.github.com/",
+			"",
+			[2]string{"https://api.github.com/", "https://uploads.github.com/"},
+		},
+		{
+			"enterprise",
+			"https://ghe.example.com/api/v3",
+			"",
+			[2]string{"https://ghe.example.com/api/v3/", "https://ghe.example.com/api/uploads/"},
+		},
+		{
+			"enterprise-with-upload-url",
+			"https://ghe.example.com/api/v3/",
+			"https://uploads.ghe.example.com/",
+			[2]string{"https://ghe.example.com/api/v3/", "https://uploads.ghe.example.com/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient("owner", "repo", "token", tt.baseURL, tt.uploadURL)
+			assert.Equal(t, tt.expected, [2]string{client.BaseURL.String(), client.UploadURL.String()})
+		})
+	}
+}
+
+func TestClient_UserAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		fmt.Fprint(w, `{"id": 1, "tag_name": "redis-1.2.3"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL)
+	_, err := client.GetRelease(context.Background(), "redis-1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, version.UserAgent(), userAgent)
+}
+
+func TestClient_WithHeader(t *testing.T) {
+	for _, token := range []string{"", "token"} {
+		t.Run("token="+token, func(t *testing.T) {
+			var received http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header
+				fmt.Fprint(w, `{"id": 1, "tag_name": "redis-1.2.3"}`)
+			}))
+			defer server.Close()
+
+			header := http.Header{}
+			header.Set("User-Agent", "chart-releaser/1.0.0")
+			header.Set("X-Proxy-Token", "secret")
+			client := NewClient("owner", "repo", token, server.URL, server.URL, WithHeader(header))
+			_, err := client.GetRelease(context.Background(), "redis-1.2.3")
+			assert.NoError(t, err)
+			assert.Equal(t, "chart-releaser/1.0.0", received.Get("User-Agent"))
+			assert.Equal(t, "secret", received.Get("X-Proxy-Token"))
+			if token != "" {
+				assert.Equal(t, "Bearer token", received.Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestClient_WithTransport(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		fmt.Fprint(w, `{"id": 1, "tag_name": "redis-1.2.3"}`)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+
+	transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	client := NewClient("owner", "repo", "token", "http://ghe.example.com/api/v3/", "", WithTransport(transport))
+	_, err = client.GetRelease(context.Background(), "redis-1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://ghe.example.com/api/v3/repos/owner/repo/releases/tags/redis-1.2.3", proxied)
+}
+
+func TestClient_UploadAssetsContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		asset       Asset
+		contentType string
+		label       string
+	}{
+		{
+			"chart-package",
+			Asset{Path: "test-chart-0.1.0.tgz"},
+			"application/gzip",
+			"",
+		},
+		{
+			"provenance-file",
+			Asset{Path: "test-chart-0.1.0.tgz.prov", Label: "Provenance"},
+			"application/pgp-signature",
+			"Provenance",
+		},
+		{
+			"explicit-content-type",
+			Asset{Path: "test-chart-0.1.0.tgz", ContentType: "application/x-tar"},
+			"application/x-tar",
+			"",
+		},
+		{
+			"other-extension",
+			Asset{Path: "notes.txt"},
+			"text/plain; charset=utf-8",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var contentType, label, name string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				label = r.URL.Query().Get("label")
+				name = r.URL.Query().Get("name")
+				fmt.Fprint(w, `{"id": 1}`)
+			}))
+			defer server.Close()
+
+			asset := tt.asset
+			asset.Path = filepath.Join(t.TempDir(), asset.Path)
+			assert.NoError(t, ioutil.WriteFile(asset.Path, []byte("content"), 0644))
+
+			client := NewClient("owner", "repo", "", server.URL, server.URL)
+			err := client.UploadAssets(context.Background(), &Release{ID: 1}, []*Asset{&asset})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.contentType, contentType)
+			assert.Equal(t, tt.label, label)
+			assert.Equal(t, filepath.Base(tt.asset.Path), name)
+		})
+	}
+}
+
+func TestClient_UploadAssetsLargeFile(t *testing.T) {
+	const size = 64 << 20
+	path := filepath.Join(t.TempDir(), "large-chart-0.1.0.tgz")
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Truncate(size))
+	assert.NoError(t, f.Close())
+
+	var received, contentLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		received, _ = io.Copy(ioutil.Discard, r.Body)
+		fmt.Fprint(w, `{"id": 1}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	logger, err := logging.New(logging.FormatText, &out)
+	assert.NoError(t, err)
+	client := NewClient("owner", "repo", "", server.URL, server.URL, WithLogger(logger))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	assert.NoError(t, client.UploadAssets(context.Background(), &Release{ID: 1}, []*Asset{{Path: path}}))
+	runtime.ReadMemStats(&after)
+
+	assert.Equal(t, int64(size), contentLength)
+	assert.Equal(t, int64(size), received)
+	// the file is streamed instead of being read into memory
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(size/4))
+	assert.Equal(t, []string{
+		"Uploaded 25% of large-chart-0.1.0.tgz (16777216 of 67108864 bytes)",
+		"Uploaded 50% of large-chart-0.1.0.tgz (33554432 of 67108864 bytes)",
+		"Uploaded 75% of large-chart-0.1.0.tgz (50331648 of 67108864 bytes)",
+		"Uploaded 100% of large-chart-0.1.0.tgz (67108864 of 67108864 bytes)",
+	}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+}
+
+func TestClient_UploadAssetsRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		skipExisting bool
+		state        string
+		uploads      int
+		deletes      int
+	}{
+		{
+			"partial-asset",
+			false,
+			"starter",
+			2,
+			1,
+		},
+		{
+			"partial-asset-skip-existing",
+			true,
+			"starter",
+			2,
+			1,
+		},
+		{
+			"uploaded-asset",
+			false,
+			"uploaded",
+			2,
+			1,
+		},
+		{
+			"uploaded-asset-skip-existing",
+			true,
+			"uploaded",
+			1,
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var uploads, deletes int
+			var uploaded []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/repos/owner/repo/releases/1/assets":
+					uploads++
+					if uploads == 1 {
+						// the upload fails after GitHub registered the asset
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					uploaded, _ = ioutil.ReadAll(r.Body)
+					fmt.Fprint(w, `{"id": 8}`)
+				case r.Method == http.MethodGet && r.URL.Path == "/repos/owner/repo/releases/1/assets":
+					fmt.Fprintf(w, `[{"id": 7, "name": "test-chart-0.1.0.tgz", "state": %q}]`, tt.state)
+				case r.Method == http.MethodDelete && r.URL.Path == "/repos/owner/repo/releases/assets/7":
+					deletes++
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			path := filepath.Join(t.TempDir(), "test-chart-0.1.0.tgz")
+			assert.NoError(t, ioutil.WriteFile(path, []byte("content"), 0644))
+
+			client := NewClient("owner", "repo", "", server.URL, server.URL, WithRetries(1, time.Millisecond), WithSkipExisting(tt.skipExisting))
+			err := client.UploadAssets(context.Background(), &Release{ID: 1, Tag: "test-chart-0.1.0"}, []*Asset{{Path: path}})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.uploads, uploads)
+			assert.Equal(t, tt.deletes, deletes)
+			if tt.uploads > 1 {
+				// the retried upload sends the whole file again
+				assert.Equal(t, "content", string(uploaded))
+			}
+		})
+	}
+}
+
+func TestClient_UploadAssetsMissingFile(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL, WithRetries(3, time.Second))
+	start := time.Now()
+	err := client.UploadAssets(context.Background(), &Release{ID: 1, Tag: "test-chart-0.1.0"},
+		[]*Asset{{Path: filepath.Join(t.TempDir(), "test-chart-0.1.0.tgz")}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open file")
+	// a local file error is not retried
+	assert.Equal(t, 0, requests)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestClient_UploadAssetsPartialFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "test-chart-0.1.0.tgz.prov" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "Validation Failed"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id": 1}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var assets []*Asset
+	for _, name := range []string{"test-chart-0.1.0.tgz", "test-chart-0.1.0.tgz.prov", "test-chart-0.1.0.tgz.sig"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte("content"), 0644))
+		assets = append(assets, &Asset{Path: path})
+	}
+
+	client := NewClient("owner", "repo", "", server.URL, server.URL)
+	err := client.UploadAssets(context.Background(), &Release{ID: 1, Tag: "test-chart-0.1.0"}, assets)
+	var uploadErr *UploadError
+	if assert.True(t, errors.As(err, &uploadErr)) {
+		assert.Equal(t, []string{"test-chart-0.1.0.tgz", "test-chart-0.1.0.tgz.sig"}, uploadErr.Uploaded)
+		assert.Len(t, uploadErr.Failed, 1)
+		assert.Contains(t, uploadErr.Failed, "test-chart-0.1.0.tgz.prov")
+	}
+}
+
+func TestClient_CreateReleaseGenerateReleaseNotes(t *testing.T) {
+	tests := []struct {
+		name        string
+		previousTag string
+		generate    interface{}
+		body        string
+		notesCalls  int
+	}{
+		{
+			"generated-by-github",
+			"",
+			true,
+			"Chart description",
+			0,
+		},
+		{
+			"since-previous-tag",
+			"test-chart-0.9.0",
+			nil,
+			"Chart description\n\n## What's Changed\n* Fix things",
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent map[string]interface{}
+			var notesRequest generateNotesRequest
+			var notesCalls int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/repos/owner/repo/releases/generate-notes" {
+					notesCalls++
+					assert.NoError(t, json.NewDecoder(r.Body).Decode(&notesRequest))
+					fmt.Fprint(w, `{"name": "test-chart-1.0.0", "body": "## What's Changed\n* Fix things"}`)
+					return
+				}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&sent))
+				fmt.Fprint(w, `{"id": 1}`)
+			}))
+			defer server.Close()
+
+			client := NewClient("owner", "repo", "", server.URL, server.URL)
+			err := client.CreateRelease(context.Background(), &Release{
+				Name:                 "test-chart-1.0.0",
+				Tag:                  "test-chart-1.0.0",
+				Description:          "Chart description",
+				GenerateReleaseNotes: true,
+				PreviousTag:          tt.previousTag,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.generate, sent["generate_release_notes"])
+			assert.Equal(t, tt.body, sent["body"])
+			assert.Equal(t, tt.notesCalls, notesCalls)
+			if tt.notesCalls > 0 {
+				assert.Equal(t, generateNotesRequest{TagName: "test-chart-1.0.0", PreviousTagName: tt.previousTag}, notesRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"io"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// progressMinSize is the size from which the progress of uploading an asset
+// is logged. Smaller assets are uploaded quickly enough.
+const progressMinSize = 16 << 20
+
+// progressReader logs the progress of reading an asset while it is streamed to
+// GitHub, once for every quarter of its size.
+type progressReader struct {
+	io.Reader
+	logger *logging.Logger
+	name   string
+	size   int64
+	read   int64
+	steps  int64
+}
+
+// newProgressReader returns a reader of the asset logging the progress of
+// reading it, if it is large enough to be worth it.
+func newProgressReader(r io.Reader, logger *logging.Logger, name string, size int64) io.Reader {
+	if size < progressMinSize {
+		return r
+	}
+	return &progressReader{Reader: r, logger: logger, name: name, size: size}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.Reader.Read(b)
+	p.read += int64(n)
+	if steps := p.read * 4 / p.size; steps > p.steps && steps <= 4 {
+		p.steps = steps
+		p.logger.Event("upload-progress", logging.Fields{"asset": p.name, "bytes": p.read, "size": p.size},
+			"Uploaded %d%% of %s (%d of %d bytes)", steps*25, p.name, p.read, p.size)
+	}
+	return n, err
+}
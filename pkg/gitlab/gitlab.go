@@ -0,0 +1,257 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xanzy/go-gitlab"
+
+	"github.com/helm/chart-releaser/pkg/github"
+)
+
+// DefaultBaseURL is the API endpoint of gitlab.com
+const DefaultBaseURL = "https://gitlab.com/api/v4/"
+
+// Client is the client for interacting with the GitLab API. Releases and
+// assets are represented with the types of the github package, so that the
+// client can be used in place of the GitHub client.
+type Client struct {
+	owner string
+	repo  string
+	*gitlab.Client
+}
+
+// NewClient creates and initializes a new GitLab client
+func NewClient(owner, repo, token, baseURL string) (*Client, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client, err := gitlab.NewClient(token, gitlab.WithBaseURL(baseURL))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GitLab client")
+	}
+
+	return &Client{
+		owner:  owner,
+		repo:   repo,
+		Client: client,
+	}, nil
+}
+
+func (c *Client) project() string {
+	return fmt.Sprintf("%s/%s", c.owner, c.repo)
+}
+
+// GetRelease queries the GitLab API for a specified release object
+func (c *Client) GetRelease(ctx context.Context, tag string) (*github.Release, error) {
+	release, resp, err := c.Releases.GetRelease(c.project(), tag, gitlab.WithContext(ctx))
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, github.ReleaseNotFound(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toRelease(release), nil
+}
+
+// GetCommitSHA returns the SHA of the commit the given ref, i.e. a commit SHA,
+// a branch or "tags/<tag>", points at. It returns an empty string if there is
+// no such ref.
+func (c *Client) GetCommitSHA(ctx context.Context, ref string) (string, error) {
+	if tag := strings.TrimPrefix(ref, "tags/"); tag != ref {
+		t, resp, err := c.Tags.GetTag(c.project(), tag, gitlab.WithContext(ctx))
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return t.Commit.ID, nil
+	}
+	commit, resp, err := c.Commits.GetCommit(c.project(), ref, gitlab.WithContext(ctx))
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return commit.ID, nil
+}
+
+// GetReleaseByID is not supported by GitLab, which identifies releases by
+// their tag only
+func (c *Client) GetReleaseByID(ctx context.Context, id int64) (*github.Release, error) {
+	return nil, errors.Errorf("getting release %d by ID is not supported by GitLab, releases are identified by their tag", id)
+}
+
+// ListReleases queries the GitLab API for all releases of the project
+func (c *Client) ListReleases(ctx context.Context) ([]*github.Release, error) {
+	var result []*github.Release
+	opts := &gitlab.ListReleasesOptions{PerPage: 100}
+	for {
+		releases, resp, err := c.Releases.ListReleases(c.project(), opts, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, release := range releases {
+			result = append(result, toRelease(release))
+		}
+		if resp.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// DeleteRelease deletes a release object. The tag of the release and the
+// uploaded project files are kept.
+func (c *Client) DeleteRelease(ctx context.Context, release *github.Release) error {
+	_, _, err := c.Releases.DeleteRelease(c.project(), release.Tag, gitlab.WithContext(ctx))
+	return err
+}
+
+// PublishRelease is not supported by GitLab, which has no draft releases
+func (c *Client) PublishRelease(ctx context.Context, release *github.Release) error {
+	return errors.Errorf("publishing release %s is not supported by GitLab, which has no draft releases", release.Tag)
+}
+
+// DeleteTag deletes the given tag from the project
+func (c *Client) DeleteTag(ctx context.Context, tag string) error {
+	_, err := c.Tags.DeleteTag(c.project(), tag, gitlab.WithContext(ctx))
+	return err
+}
+
+func toRelease(release *gitlab.Release) *github.Release {
+	result := &github.Release{
+		Name:        release.Name,
+		Tag:         release.TagName,
+		Description: release.Description,
+		Assets:      []*github.Asset{},
+	}
+	if release.CreatedAt != nil {
+		result.CreatedAt = *release.CreatedAt
+	}
+	for _, link := range release.Assets.Links {
+		result.Assets = append(result.Assets, &github.Asset{Path: link.Name, URL: link.URL})
+	}
+	return result
+}
+
+// CreateRelease creates a new release object in the GitLab API
+func (c *Client) CreateRelease(ctx context.Context, input *github.Release) error {
+	if input.Draft {
+		return errors.New("draft releases are not supported by GitLab")
+	}
+	opts := &gitlab.CreateReleaseOptions{
+		Name:        &input.Name,
+		TagName:     &input.Tag,
+		Description: &input.Description,
+	}
+	if input.Commit != "" {
+		opts.Ref = &input.Commit
+	}
+
+	if _, _, err := c.Releases.CreateRelease(c.project(), opts, gitlab.WithContext(ctx)); err != nil {
+		return err
+	}
+
+	return c.UploadAssets(ctx, input, input.Assets)
+}
+
+// UploadAssets uploads the given assets as project files and links them to an
+// existing release object
+func (c *Client) UploadAssets(ctx context.Context, release *github.Release, assets []*github.Asset) error {
+	if len(assets) == 0 {
+		return nil
+	}
+
+	project, _, err := c.Projects.GetProject(c.project(), nil, gitlab.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	for _, asset := range assets {
+		file, err := c.uploadFile(ctx, asset)
+		if err != nil {
+			return errors.Wrapf(err, "failed to upload release asset: %s", asset.Path)
+		}
+
+		name := asset.FileName()
+		url := project.WebURL + file.URL
+		linkOpts := &gitlab.CreateReleaseLinkOptions{
+			Name: &name,
+			URL:  &url,
+		}
+		if _, _, err := c.ReleaseLinks.CreateReleaseLink(c.project(), release.Tag, linkOpts, gitlab.WithContext(ctx)); err != nil {
+			return errors.Wrapf(err, "failed to link release asset: %s", asset.Path)
+		}
+	}
+	return nil
+}
+
+// uploadFile uploads the asset as project file. GitLab names project files
+// after the uploaded file, so assets with a name other than the one of their
+// file are uploaded from a copy with that name.
+func (c *Client) uploadFile(ctx context.Context, asset *github.Asset) (*gitlab.ProjectFile, error) {
+	path := asset.Path
+	if asset.FileName() != filepath.Base(asset.Path) {
+		dir, err := ioutil.TempDir("", "chart-releaser-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		content, err := ioutil.ReadFile(asset.Path)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, asset.FileName())
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			return nil, err
+		}
+	}
+	file, _, err := c.Projects.UploadFile(c.project(), path, gitlab.WithContext(ctx))
+	return file, err
+}
+
+// CreatePullRequest creates a merge request in the repository specified by
+// owner and repo. The return value is the merge request URL.
+func (c *Client) CreatePullRequest(owner string, repo string, message string, head string, base string) (string, error) {
+	split := strings.SplitN(message, "\n", 2)
+	title := split[0]
+
+	opts := &gitlab.CreateMergeRequestOptions{
+		Title:        &title,
+		SourceBranch: &head,
+		TargetBranch: &base,
+	}
+	if len(split) == 2 {
+		body := strings.TrimSpace(split[1])
+		opts.Description = &body
+	}
+
+	mergeRequest, _, err := c.MergeRequests.CreateMergeRequest(fmt.Sprintf("%s/%s", owner, repo), opts)
+	if err != nil {
+		return "", err
+	}
+	return mergeRequest.WebURL, nil
+}
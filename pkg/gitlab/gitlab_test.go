@@ -0,0 +1,204 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xanzy/go-gitlab"
+
+	"github.com/helm/chart-releaser/pkg/github"
+)
+
+// fakeGitLab is an in-memory implementation of the parts of the GitLab API
+// used by the client.
+type fakeGitLab struct {
+	sync.Mutex
+	releases []*gitlab.Release
+	refs     map[string]string
+	uploads  map[string]string
+	header   http.Header
+}
+
+func newFakeGitLab(t *testing.T) (*fakeGitLab, *Client) {
+	f := &fakeGitLab{refs: map[string]string{}, uploads: map[string]string{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	c, err := NewClient("owner", "repo", "token", server.URL+"/api/v4/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, c
+}
+
+func (f *fakeGitLab) find(tag string) *gitlab.Release {
+	for _, rel := range f.releases {
+		if rel.TagName == tag {
+			return rel
+		}
+	}
+	return nil
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.header = r.Header
+
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v4/projects/owner/repo"), "/")
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		json.NewEncoder(w).Encode(map[string]string{"web_url": "https://gitlab.example.com/owner/repo"})
+	case r.Method == http.MethodPost && path == "releases":
+		var input struct {
+			Name        string `json:"name"`
+			TagName     string `json:"tag_name"`
+			Description string `json:"description"`
+			Ref         string `json:"ref"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rel := &gitlab.Release{Name: input.Name, TagName: input.TagName, Description: input.Description}
+		f.releases = append(f.releases, rel)
+		f.refs[input.TagName] = input.Ref
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rel)
+	case r.Method == http.MethodPost && path == "uploads":
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, _ := ioutil.ReadAll(file)
+		f.uploads[header.Filename] = string(content)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(gitlab.ProjectFile{URL: "/uploads/0123/" + header.Filename})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/assets/links"):
+		rel := f.find(strings.TrimSuffix(strings.TrimPrefix(path, "releases/"), "/assets/links"))
+		var link gitlab.ReleaseLink
+		if err := json.NewDecoder(r.Body).Decode(&link); rel == nil || err != nil {
+			http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+			return
+		}
+		link.ID = len(rel.Assets.Links) + 1
+		rel.Assets.Links = append(rel.Assets.Links, &link)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(link)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "releases/"):
+		if rel := f.find(strings.TrimPrefix(path, "releases/")); rel != nil {
+			json.NewEncoder(w).Encode(rel)
+			return
+		}
+		http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+	default:
+		http.Error(w, `{"message":"500 Internal Server Error"}`, http.StatusInternalServerError)
+	}
+}
+
+func TestClient_CreateRelease(t *testing.T) {
+	f, c := newFakeGitLab(t)
+
+	dir := t.TempDir()
+	chartPackage := filepath.Join(dir, "test-chart-0.1.0.tgz")
+	assert.NoError(t, ioutil.WriteFile(chartPackage, []byte("chart"), 0644))
+
+	input := &github.Release{
+		Name:        "test-chart-0.1.0",
+		Tag:         "test-chart-0.1.0",
+		Description: "A Helm chart",
+		Commit:      "main",
+		Assets:      []*github.Asset{{Path: chartPackage}},
+	}
+	assert.NoError(t, c.CreateRelease(context.TODO(), input))
+	assert.Equal(t, map[string]string{"test-chart-0.1.0.tgz": "chart"}, f.uploads)
+	assert.Equal(t, "main", f.refs["test-chart-0.1.0"])
+	assert.Equal(t, "token", f.header.Get("Private-Token"))
+
+	// draft releases do not exist in GitLab
+	err := c.CreateRelease(context.TODO(), &github.Release{Tag: "draft-0.1.0", Draft: true})
+	assert.EqualError(t, err, "draft releases are not supported by GitLab")
+	assert.Len(t, f.releases, 1)
+}
+
+func TestClient_GetRelease(t *testing.T) {
+	f, c := newFakeGitLab(t)
+	f.releases = []*gitlab.Release{{Name: "test-chart-0.1.0", TagName: "test-chart-0.1.0", Description: "A Helm chart"}}
+	f.releases[0].Assets.Links = []*gitlab.ReleaseLink{
+		{ID: 1, Name: "test-chart-0.1.0.tgz", URL: "https://gitlab.example.com/owner/repo/uploads/0123/test-chart-0.1.0.tgz"},
+	}
+
+	release, err := c.GetRelease(context.TODO(), "test-chart-0.1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-chart-0.1.0", release.Name)
+	assert.Equal(t, "test-chart-0.1.0", release.Tag)
+	assert.Equal(t, "A Helm chart", release.Description)
+	assert.Equal(t, []*github.Asset{
+		{Path: "test-chart-0.1.0.tgz", URL: "https://gitlab.example.com/owner/repo/uploads/0123/test-chart-0.1.0.tgz"},
+	}, release.Assets)
+
+	_, err = c.GetRelease(context.TODO(), "missing-0.1.0")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+	assert.True(t, errors.Is(err, github.ErrReleaseNotFound))
+}
+
+func TestClient_UploadAssets(t *testing.T) {
+	f, c := newFakeGitLab(t)
+	f.releases = []*gitlab.Release{{Name: "test-chart-0.1.0", TagName: "test-chart-0.1.0"}}
+
+	dir := t.TempDir()
+	chartPackage := filepath.Join(dir, "test-chart-0.1.0.tgz")
+	assert.NoError(t, ioutil.WriteFile(chartPackage, []byte("chart"), 0644))
+	provenance := filepath.Join(dir, "test-chart-0.1.0.tgz.prov")
+	assert.NoError(t, ioutil.WriteFile(provenance, []byte("signature"), 0644))
+
+	release := &github.Release{Tag: "test-chart-0.1.0"}
+	assets := []*github.Asset{
+		{Path: chartPackage},
+		// uploaded from a copy named after the asset
+		{Path: provenance, Name: "test-chart.prov"},
+	}
+	assert.NoError(t, c.UploadAssets(context.TODO(), release, assets))
+	assert.Equal(t, map[string]string{"test-chart-0.1.0.tgz": "chart", "test-chart.prov": "signature"}, f.uploads)
+
+	var links []string
+	for _, link := range f.releases[0].Assets.Links {
+		links = append(links, link.Name+" "+link.URL)
+	}
+	assert.Equal(t, []string{
+		"test-chart-0.1.0.tgz https://gitlab.example.com/owner/repo/uploads/0123/test-chart-0.1.0.tgz",
+		"test-chart.prov https://gitlab.example.com/owner/repo/uploads/0123/test-chart.prov",
+	}, links)
+
+	// the assets can not be linked to a release which does not exist
+	err := c.UploadAssets(context.TODO(), &github.Release{Tag: "missing-0.1.0"}, []*github.Asset{{Path: chartPackage}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to link release asset")
+
+	assert.NoError(t, c.UploadAssets(context.TODO(), release, nil))
+}
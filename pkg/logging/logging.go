@@ -0,0 +1,143 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatText logs human readable messages, one per line
+	FormatText = "text"
+	// FormatJSON logs one JSON object per line
+	FormatJSON = "json"
+)
+
+// Fields are the structured fields of a logged event
+type Fields map[string]interface{}
+
+// Logger logs messages either as text or as JSON lines. A nil Logger logs
+// text to stdout.
+type Logger struct {
+	format string
+	out    io.Writer
+	mu     sync.Mutex
+}
+
+// New creates a Logger writing in the given format to out
+func New(format string, out io.Writer) (*Logger, error) {
+	switch format {
+	case "":
+		format = FormatText
+	case FormatText, FormatJSON:
+	default:
+		return nil, errors.Errorf("unknown log format %q, must be one of: %s, %s", format, FormatText, FormatJSON)
+	}
+	return &Logger{format: format, out: out}, nil
+}
+
+// SetOutput makes the Logger write to out from now on
+func (l *Logger) SetOutput(out io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = out
+}
+
+// Printf logs a message without structured fields
+func (l *Logger) Printf(format string, a ...interface{}) {
+	l.Event("", nil, format, a...)
+}
+
+// Event logs a message for the given action. The fields are only part of the
+// output in JSON format, text output consists of the message alone.
+func (l *Logger) Event(action string, fields Fields, format string, a ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintf(format, a...), "\n")
+	if l == nil {
+		fmt.Fprintln(os.Stdout, msg)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.format != FormatJSON {
+		fmt.Fprintln(l.out, msg)
+		return
+	}
+
+	event := make(map[string]interface{}, len(fields)+3)
+	for k, v := range fields {
+		event[k] = v
+	}
+	event["time"] = time.Now().UTC().Format(time.RFC3339)
+	event["msg"] = msg
+	if action != "" {
+		event["action"] = action
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{"msg": msg, "error": err.Error()})
+	}
+	fmt.Fprintln(l.out, string(line))
+}
+
+// Writer returns a writer logging every line written to it as an event of the
+// given action, e.g. for capturing the output of a command. Close logs the
+// last line if it is not terminated by a newline.
+func (l *Logger) Writer(action string, fields Fields) io.WriteCloser {
+	return &lineWriter{logger: l, action: action, fields: fields}
+}
+
+type lineWriter struct {
+	logger *Logger
+	action string
+	fields Fields
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.logger.Event(w.action, w.fields, "%s", strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+}
+
+func (w *lineWriter) Close() error {
+	if len(w.buf) > 0 {
+		w.logger.Event(w.action, w.fields, "%s", string(w.buf))
+		w.buf = nil
+	}
+	return nil
+}
+
+// DurationMillis returns the time passed since start in milliseconds, for use
+// as duration_ms field.
+func DurationMillis(start time.Time) int64 {
+	return time.Since(start).Milliseconds()
+}
@@ -0,0 +1,109 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger_Event(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   map[string]interface{}
+		text   string
+	}{
+		{
+			"text",
+			FormatText,
+			nil,
+			"Created release test-chart-0.1.0\n",
+		},
+		{
+			"json",
+			FormatJSON,
+			map[string]interface{}{
+				"action": "create-release",
+				"chart":  "test-chart",
+				"msg":    "Created release test-chart-0.1.0",
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			l, err := New(tt.format, &out)
+			assert.NoError(t, err)
+			l.Event("create-release", Fields{"chart": "test-chart"}, "Created release %s\n", "test-chart-0.1.0")
+			if tt.want == nil {
+				assert.Equal(t, tt.text, out.String())
+				return
+			}
+			var event map[string]interface{}
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &event))
+			assert.Contains(t, event, "time")
+			delete(event, "time")
+			assert.Equal(t, tt.want, event)
+		})
+	}
+}
+
+func TestLogger_SetOutput(t *testing.T) {
+	var first, second bytes.Buffer
+	l, err := New(FormatText, &first)
+	assert.NoError(t, err)
+	l.Printf("first")
+	l.SetOutput(&second)
+	l.Printf("second")
+	assert.Equal(t, "first\n", first.String())
+	assert.Equal(t, "second\n", second.String())
+}
+
+func TestLogger_Writer(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(FormatJSON, &out)
+	assert.NoError(t, err)
+	w := l.Writer("cosign", Fields{"file": "test-chart-0.1.0.tgz"})
+	_, err = w.Write([]byte("Using payload from: test-chart-0.1.0.tgz\nWrote sig"))
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("nature to file test-chart-0.1.0.tgz.sig\r\nDone"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	var msgs []string
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var event map[string]interface{}
+		assert.NoError(t, decoder.Decode(&event))
+		assert.Equal(t, "cosign", event["action"])
+		assert.Equal(t, "test-chart-0.1.0.tgz", event["file"])
+		msgs = append(msgs, event["msg"].(string))
+	}
+	assert.Equal(t, []string{
+		"Using payload from: test-chart-0.1.0.tgz",
+		"Wrote signature to file test-chart-0.1.0.tgz.sig",
+		"Done",
+	}, msgs)
+}
+
+func TestNew_UnknownFormat(t *testing.T) {
+	_, err := New("xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -19,12 +19,20 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/downloader"
 	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
 
 	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/logging"
 	"helm.sh/helm/v3/pkg/action"
 )
 
@@ -32,6 +40,9 @@ import (
 type Packager struct {
 	config *config.Options
 	paths  []string
+
+	// Logger logs the packaged charts, to stdout if it is nil.
+	Logger *logging.Logger
 }
 
 // NewPackager returns a configured Packager
@@ -44,8 +55,12 @@ func NewPackager(config *config.Options, paths []string) *Packager {
 
 // CreatePackages creates Helm chart packages
 func (p *Packager) CreatePackages() error {
+	if err := checkDuplicateNames(p.paths); err != nil {
+		return err
+	}
+
 	helmClient := action.NewPackage()
-	helmClient.DependencyUpdate = true
+	helmClient.DependencyUpdate = p.config.PackageWithDependencyUpdate
 	helmClient.Destination = p.config.PackagePath
 	if p.config.Sign {
 		helmClient.Sign = true
@@ -57,6 +72,29 @@ func (p *Packager) CreatePackages() error {
 	settings := cli.New()
 	getters := getter.All(settings)
 
+	valueOpts := &values.Options{ValueFiles: p.config.PackageValues, Values: p.config.PackageSet}
+	overrides, err := valueOpts.MergeValues(getters)
+	if err != nil {
+		return err
+	}
+	if len(overrides) > 0 {
+		// The package is signed once the values have been applied
+		helmClient.Sign = false
+	}
+
+	if len(p.config.DependencyRepos) > 0 {
+		dir, err := ioutil.TempDir("", "chart-releaser-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		repositoryConfig := filepath.Join(dir, "repositories.yaml")
+		if err := writeRepositoryConfig(repositoryConfig, settings.RepositoryConfig, p.config.DependencyRepos); err != nil {
+			return err
+		}
+		settings.RepositoryConfig = repositoryConfig
+	}
+
 	for i := 0; i < len(p.paths); i++ {
 		path, err := filepath.Abs(p.paths[i])
 		if err != nil {
@@ -80,11 +118,97 @@ func (p *Packager) CreatePackages() error {
 		}
 		packageRun, err := helmClient.Run(path, nil)
 		if err != nil {
-			fmt.Printf("Failed to package chart in %s (%s)\n", path, err.Error())
+			p.Logger.Event("package-failed", logging.Fields{"path": path, "error": err.Error()}, "Failed to package chart in %s (%s)", path, err.Error())
 			return err
 		}
+		if len(overrides) > 0 {
+			if err := applyValues(packageRun, overrides); err != nil {
+				return errors.Wrapf(err, "failed to apply values to %s", packageRun)
+			}
+			if p.config.Sign {
+				if err := helmClient.Clearsign(packageRun); err != nil {
+					return err
+				}
+			}
+		}
 
-		fmt.Printf("Successfully packaged chart in %s and saved it to: %s\n", path, packageRun)
+		p.Logger.Event("package", logging.Fields{"path": path, "package": packageRun}, "Successfully packaged chart in %s and saved it to: %s", path, packageRun)
+	}
+	return nil
+}
+
+// checkDuplicateNames fails if charts in different directories declare the
+// same name, whose packages would overwrite each other in the package path.
+// Directories without a readable Chart.yaml are left for packaging to report.
+func checkDuplicateNames(paths []string) error {
+	var names []string
+	pathsByName := map[string][]string{}
+	for _, path := range paths {
+		chartfile, err := chartutil.LoadChartfile(filepath.Join(path, chartutil.ChartfileName))
+		if err != nil || chartfile.Name == "" {
+			continue
+		}
+		if _, ok := pathsByName[chartfile.Name]; !ok {
+			names = append(names, chartfile.Name)
+		}
+		pathsByName[chartfile.Name] = append(pathsByName[chartfile.Name], path)
+	}
+	var duplicates []string
+	for _, name := range names {
+		if len(pathsByName[name]) > 1 {
+			duplicates = append(duplicates, fmt.Sprintf("%q in %s", name, strings.Join(pathsByName[name], ", ")))
+		}
+	}
+	if len(duplicates) > 0 {
+		return errors.Errorf("charts in different directories have the same name: %s", strings.Join(duplicates, "; "))
 	}
 	return nil
 }
+
+// applyValues merges the values into the default values of the chart package,
+// taking precedence over them, and saves the package again. The values.yaml of
+// the package is regenerated, so comments in it are not preserved.
+func applyValues(chartPackage string, vals map[string]interface{}) error {
+	ch, err := loader.LoadFile(chartPackage)
+	if err != nil {
+		return err
+	}
+	ch.Values = chartutil.CoalesceTables(vals, ch.Values)
+	content, err := chartutil.Values(ch.Values).YAML()
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	for _, f := range ch.Raw {
+		if f.Name == chartutil.ValuesfileName {
+			f.Data = []byte(content)
+			found = true
+		}
+	}
+	if !found {
+		ch.Raw = append(ch.Raw, &chart.File{Name: chartutil.ValuesfileName, Data: []byte(content)})
+	}
+	_, err = chartutil.Save(ch, filepath.Dir(chartPackage))
+	return err
+}
+
+// writeRepositoryConfig writes a Helm repository file with the repositories of
+// the given base file, if it exists, and the given name=url pairs, so that
+// dependencies can be resolved without running 'helm repo add' first.
+func writeRepositoryConfig(path string, base string, repos []string) error {
+	f := repo.NewFile()
+	if _, err := os.Stat(base); err == nil {
+		if f, err = repo.LoadFile(base); err != nil {
+			return err
+		}
+	}
+	for _, r := range repos {
+		parts := strings.SplitN(r, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid dependency repository %q, must be name=url", r)
+		}
+		f.Update(&repo.Entry{Name: parts[0], URL: parts[1]})
+	}
+	return f.WriteFile(path, 0644)
+}
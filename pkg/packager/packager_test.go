@@ -15,13 +15,19 @@
 package packager
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
 
 	"github.com/helm/chart-releaser/pkg/config"
 )
@@ -96,3 +102,123 @@ func TestPackager_CreatePackages(t *testing.T) {
 		})
 	}
 }
+
+func TestPackager_CreatePackagesDuplicateNames(t *testing.T) {
+	chartsDir := t.TempDir()
+	var paths []string
+	for _, dir := range []string{"apps/redis", "infra/redis", "infra/nginx"} {
+		path := filepath.Join(chartsDir, dir)
+		require.NoError(t, os.MkdirAll(path, 0755))
+		name := filepath.Base(dir)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(path, "Chart.yaml"), []byte("apiVersion: v2\nname: "+name+"\nversion: 0.1.0\n"), 0644))
+		paths = append(paths, path)
+	}
+
+	packagePath := t.TempDir()
+	err := NewPackager(&config.Options{PackagePath: packagePath}, paths).CreatePackages()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf(`"redis" in %s, %s`, paths[0], paths[1]))
+	assert.NotContains(t, err.Error(), "nginx")
+	packages, err := ioutil.ReadDir(packagePath)
+	require.NoError(t, err)
+	assert.Empty(t, packages)
+}
+
+func TestPackager_CreatePackagesWithDependencyRepos(t *testing.T) {
+	// keep the downloaded repository indexes out of the Helm cache of the user
+	require.NoError(t, os.Setenv("HELM_CACHE_HOME", t.TempDir()))
+	t.Cleanup(func() {
+		os.Unsetenv("HELM_CACHE_HOME")
+	})
+
+	// serve test-chart from a local chart repository
+	repoPath := t.TempDir()
+	require.NoError(t, NewPackager(&config.Options{PackagePath: repoPath}, []string{"testdata/test-chart"}).CreatePackages())
+	server := httptest.NewServer(http.FileServer(http.Dir(repoPath)))
+	defer server.Close()
+	index, err := repo.IndexDirectory(repoPath, server.URL)
+	require.NoError(t, err)
+	require.NoError(t, index.WriteFile(filepath.Join(repoPath, "index.yaml"), 0644))
+
+	umbrellaPath := filepath.Join(t.TempDir(), "umbrella")
+	require.NoError(t, os.Mkdir(umbrellaPath, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(umbrellaPath, "Chart.yaml"), []byte(`apiVersion: v2
+name: umbrella
+version: 0.1.0
+dependencies:
+- name: test-chart
+  version: 0.1.0
+  repository: "@local"
+`), 0644))
+
+	tests := []struct {
+		name            string
+		dependencyRepos []string
+		error           bool
+	}{
+		{
+			name:  "missing-repo",
+			error: true,
+		},
+		{
+			name:            "invalid-repo",
+			dependencyRepos: []string{"local"},
+			error:           true,
+		},
+		{
+			name:            "dependency-repo",
+			dependencyRepos: []string{"local=" + server.URL},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packagePath := t.TempDir()
+			p := NewPackager(&config.Options{PackagePath: packagePath, DependencyRepos: tt.dependencyRepos}, []string{umbrellaPath})
+			err := p.CreatePackages()
+			if tt.error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			ch, err := loader.LoadFile(filepath.Join(packagePath, "umbrella-0.1.0.tgz"))
+			require.NoError(t, err)
+			require.Len(t, ch.Dependencies(), 1)
+			assert.Equal(t, "test-chart", ch.Dependencies()[0].Name())
+		})
+	}
+}
+
+func TestPackager_CreatePackagesWithValues(t *testing.T) {
+	packagePath := t.TempDir()
+	valuesFile := filepath.Join(t.TempDir(), "production.yaml")
+	require.NoError(t, ioutil.WriteFile(valuesFile, []byte("replicaCount: 3\nimage:\n  tag: \"1.21\"\n"), 0644))
+
+	p := &Packager{
+		paths: []string{"testdata/test-chart"},
+		config: &config.Options{
+			PackagePath:    packagePath,
+			PackageValues:  []string{valuesFile},
+			PackageSet:     []string{"image.pullPolicy=Always"},
+			Sign:           true,
+			Key:            "Chart Releaser Test Key <no-reply@example.com>",
+			KeyRing:        "testdata/testkeyring.gpg",
+			PassphraseFile: "testdata/passphrase-file.txt",
+		},
+	}
+	require.NoError(t, p.CreatePackages())
+
+	chartPackage := filepath.Join(packagePath, "test-chart-0.1.0.tgz")
+	ch, err := loader.LoadFile(chartPackage)
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), ch.Values["replicaCount"])
+	image := ch.Values["image"].(map[string]interface{})
+	assert.Equal(t, "1.21", image["tag"])
+	assert.Equal(t, "Always", image["pullPolicy"])
+	assert.Equal(t, "nginx", image["repository"])
+
+	// the provenance file must match the package with the applied values
+	signatory, err := provenance.NewFromKeyring("testdata/testkeyring.gpg", "")
+	require.NoError(t, err)
+	_, err = signatory.Verify(chartPackage, chartPackage+".prov")
+	assert.NoError(t, err)
+}
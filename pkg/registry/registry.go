@@ -0,0 +1,86 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// Registry pushes chart packages to an OCI registry using the helm CLI. The
+// registry client of the helm library is not public in the Helm version we depend on.
+type Registry struct {
+	// Logger logs the output of the helm CLI, stdout if it is nil.
+	Logger *logging.Logger
+}
+
+// Login runs 'helm registry login' for the host of the given oci:// URL. The
+// password is passed via stdin so that it does not show up in process listings.
+func (r *Registry) Login(registryURL string, username string, password string) error {
+	host, err := Host(registryURL)
+	if err != nil {
+		return err
+	}
+	command := exec.Command("helm", "registry", "login", host, "--username", username, "--password-stdin")
+	command.Stdin = strings.NewReader(password)
+	return r.runCommand(command)
+}
+
+// Exists checks whether the given chart version has already been pushed to the registry.
+func (r *Registry) Exists(registryURL string, name string, version string) (bool, error) {
+	ref := fmt.Sprintf("%s/%s", strings.TrimSuffix(registryURL, "/"), name)
+	command := exec.Command("helm", "show", "chart", ref, "--version", version)
+	command.Stdout = ioutil.Discard
+	command.Stderr = ioutil.Discard
+	if err := command.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Push runs 'helm push' for the given chart package.
+func (r *Registry) Push(chartPackage string, registryURL string) error {
+	command := exec.Command("helm", "push", chartPackage, registryURL)
+	return r.runCommand(command)
+}
+
+// Host returns the host of the given oci:// URL.
+func Host(registryURL string) (string, error) {
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "oci" {
+		return "", fmt.Errorf("registry URL %q must start with oci://", registryURL)
+	}
+	return u.Host, nil
+}
+
+// runCommand runs the command, logging its output.
+func (r *Registry) runCommand(command *exec.Cmd) error {
+	out := r.Logger.Writer("helm", nil)
+	defer out.Close()
+	command.Stdout = out
+	command.Stderr = out
+	return command.Run()
+}
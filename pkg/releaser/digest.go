@@ -0,0 +1,67 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"sync"
+
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+// packageDigests are the SHA-256 digests of chart packages by file.
+type packageDigests map[string]string
+
+// digestFiles computes the SHA-256 digests of the files with at most the given
+// number of files hashed in parallel.
+func digestFiles(files []string, concurrency int) (packageDigests, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	digests := make([]string, len(files))
+	errs := make([]error, len(files))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, file string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			digests[i], errs[i] = provenance.DigestFile(file)
+		}(i, file)
+	}
+	wg.Wait()
+
+	result := make(packageDigests, len(files))
+	for i, file := range files {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		result[file] = digests[i]
+	}
+	return result, nil
+}
+
+// digest returns the SHA-256 digest of the file, which is computed unless it
+// is one of the digests already.
+func (d packageDigests) digest(file string) (string, error) {
+	if digest, ok := d[file]; ok {
+		return digest, nil
+	}
+	return provenance.DigestFile(file)
+}
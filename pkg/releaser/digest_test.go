@@ -0,0 +1,127 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/helm/chart-releaser/pkg/config"
+)
+
+var digestTestPackages = []string{
+	"testdata/release-packages/test-chart-0.1.0.tgz",
+	"testdata/other-packages/other-chart-0.1.0.tgz",
+	"testdata/prerelease-packages/test-chart-1.0.0-rc.1.tgz",
+	"testdata/annotated-packages/annotated-chart-0.1.0.tgz",
+	"testdata/notes-packages/notes-chart-0.1.0.tgz",
+}
+
+func TestDigestFiles(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("concurrency-%d", concurrency), func(t *testing.T) {
+			digests, err := digestFiles(digestTestPackages, concurrency)
+			assert.NoError(t, err)
+			assert.Len(t, digests, len(digestTestPackages))
+			for _, file := range digestTestPackages {
+				expected, err := provenance.DigestFile(file)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, digests[file])
+			}
+		})
+	}
+
+	_, err := digestFiles(append(digestTestPackages, "testdata/does-not-exist.tgz"), 4)
+	assert.Error(t, err)
+}
+
+func TestPackageDigests_digest(t *testing.T) {
+	file := digestTestPackages[0]
+	expected, err := provenance.DigestFile(file)
+	assert.NoError(t, err)
+
+	// the digests are only taken from the map if they have been computed
+	for _, digests := range []packageDigests{nil, {}, {file: expected}} {
+		digest, err := digests.digest(file)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, digest)
+	}
+	digest, err := packageDigests{file: "computed"}.digest(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "computed", digest)
+}
+
+func TestReleaser_addToIndexFileParallelDigests(t *testing.T) {
+	buildIndex := func(parallel bool) []byte {
+		r := &Releaser{config: &config.Options{}}
+		var digests packageDigests
+		if parallel {
+			var err error
+			digests, err = digestFiles(digestTestPackages, 4)
+			assert.NoError(t, err)
+		}
+		indexFile := repo.NewIndexFile()
+		for _, file := range digestTestPackages {
+			assert.NoError(t, r.addToIndexFile(indexFile, file, "https://example.com/"+filepath.Base(file), digests))
+		}
+		indexFile.SortEntries()
+
+		// only the creation times may differ between the runs
+		for _, entries := range indexFile.Entries {
+			for _, entry := range entries {
+				entry.Created = time.Time{}
+			}
+		}
+		indexFile.Generated = time.Time{}
+		path := filepath.Join(t.TempDir(), "index.yaml")
+		assert.NoError(t, indexFile.WriteFile(path, 0644))
+		content, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		return content
+	}
+
+	assert.Equal(t, string(buildIndex(false)), string(buildIndex(true)))
+}
+
+func BenchmarkDigestFiles(b *testing.B) {
+	dir := b.TempDir()
+	content := make([]byte, 1<<20)
+	var files []string
+	for i := 0; i < 200; i++ {
+		file := filepath.Join(dir, fmt.Sprintf("chart-%d-0.1.0.tgz", i))
+		content[0] = byte(i)
+		if err := ioutil.WriteFile(file, content, 0644); err != nil {
+			b.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := digestFiles(files, concurrency); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
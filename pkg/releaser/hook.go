@@ -0,0 +1,68 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+
+	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// hookData is passed to the pre-release hook template. In addition to the
+// chart metadata it provides the path of the chart package.
+type hookData struct {
+	*chart.Metadata
+	Package string
+}
+
+// runPreReleaseHooks runs the pre-release hook for every chart package and
+// logs its output. All failing hooks are reported together, so that no release
+// is created if any of them fails.
+func (r *Releaser) runPreReleaseHooks(packages []string) error {
+	var failed errorList
+	for _, p := range packages {
+		ch, err := loader.LoadFile(p)
+		if err != nil {
+			return err
+		}
+		var command bytes.Buffer
+		if err := r.preReleaseHookTemplate.Execute(&command, hookData{Metadata: ch.Metadata, Package: p}); err != nil {
+			return errors.Wrapf(err, "error computing the pre-release hook of %s", p)
+		}
+
+		output, err := config.ShellCommand(command.String()).CombinedOutput()
+		out := strings.TrimSpace(string(output))
+		fields := logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "output": out}
+		if out != "" {
+			out = ":\n" + out
+		}
+		if err != nil {
+			r.logger.Event("pre-release-hook-failed", fields, "Pre-release hook of %s-%s failed%s", ch.Metadata.Name, ch.Metadata.Version, out)
+			failed = append(failed, errors.Wrapf(err, "pre-release hook of %s failed", p))
+			continue
+		}
+		r.logger.Event("pre-release-hook", fields, "Pre-release hook of %s-%s passed%s", ch.Metadata.Name, ch.Metadata.Version, out)
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
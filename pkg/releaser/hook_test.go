@@ -0,0 +1,87 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+func TestReleaser_CreateReleasesPreReleaseHook(t *testing.T) {
+	packagePath := t.TempDir()
+	for _, p := range []string{"testdata/release-packages/test-chart-0.1.0.tgz", "testdata/other-packages/other-chart-0.1.0.tgz"} {
+		assert.NoError(t, copyFile(p, filepath.Join(packagePath, filepath.Base(p))))
+	}
+
+	tests := []struct {
+		name     string
+		hook     string
+		error    string
+		output   []string
+		releases int
+	}{
+		{
+			name:     "passes",
+			hook:     "echo checked {{ .Name }}-{{ .Version }}",
+			output:   []string{"Pre-release hook of test-chart-0.1.0 passed:\nchecked test-chart-0.1.0", "Pre-release hook of other-chart-0.1.0 passed:\nchecked other-chart-0.1.0"},
+			releases: 2,
+		},
+		{
+			name:   "fails-for-one-chart",
+			hook:   `test {{ .Name }} != other-chart || { echo "{{ .Package }} is invalid"; exit 1; }`,
+			error:  "pre-release hook of " + filepath.Join(packagePath, "other-chart-0.1.0.tgz") + " failed: exit status 1",
+			output: []string{"Pre-release hook of test-chart-0.1.0 passed\n", "Pre-release hook of other-chart-0.1.0 failed:\n" + filepath.Join(packagePath, "other-chart-0.1.0.tgz") + " is invalid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, err := parseTemplate("pre-release-hook", tt.hook, nil)
+			assert.NoError(t, err)
+			var out bytes.Buffer
+			logger, err := logging.New(logging.FormatText, &out)
+			assert.NoError(t, err)
+			fakeGitHub := new(FakeGitHub)
+			fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return(nil)
+			r := &Releaser{
+				config: &config.Options{
+					PackagePath:          packagePath,
+					ReleaseNameTemplate:  "{{ .Name }}-{{ .Version }}",
+					AllowChangedVersions: true,
+				},
+				github:                 fakeGitHub,
+				logger:                 logger,
+				preReleaseHookTemplate: hook,
+			}
+			err = r.CreateReleases(context.Background())
+			if tt.error != "" {
+				assert.EqualError(t, err, tt.error)
+			} else {
+				assert.NoError(t, err)
+			}
+			for _, output := range tt.output {
+				assert.Contains(t, out.String(), output)
+			}
+			fakeGitHub.AssertNumberOfCalls(t, "CreateRelease", tt.releases)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/Masterminds/sprig/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// htmlFileName is the name of the landing page written next to the index.
+const htmlFileName = "index.html"
+
+// defaultHTMLTemplate renders the landing page if no template is configured.
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Helm Charts</title>
+</head>
+<body>
+  <h1>Helm Charts</h1>
+  {{- with .RepoURL }}
+  <p>Add the repository with <code>helm repo add &lt;name&gt; {{ . }}</code>.</p>
+  {{- end }}
+  {{- range .Charts }}
+  <h2 id="{{ .Name }}">{{ .Name }}</h2>
+  {{- with .Description }}
+  <p>{{ . }}</p>
+  {{- end }}
+  <ul>
+    {{- range .Versions }}
+    <li><a href="{{ .URL }}">{{ .Version }}</a>{{ with .AppVersion }} (app version {{ . }}){{ end }}</li>
+    {{- end }}
+  </ul>
+  {{- end }}
+  <p>Generated {{ .Generated.UTC.Format "2006-01-02 15:04:05 MST" }}</p>
+</body>
+</html>
+`
+
+// htmlData is passed to the template of the landing page.
+type htmlData struct {
+	// RepoURL is the URL of the charts repository.
+	RepoURL   string
+	Charts    []htmlChart
+	Generated time.Time
+}
+
+// htmlChart is a chart listed on the landing page, with its versions sorted
+// from the newest to the oldest.
+type htmlChart struct {
+	Name        string
+	Description string
+	Versions    []htmlVersion
+}
+
+// htmlVersion is a chart version listed on the landing page. URL is the first
+// of its URLs.
+type htmlVersion struct {
+	*repo.ChartVersion
+	URL string
+}
+
+// newHTMLData returns the data of the landing page of the index, with the
+// charts sorted by name.
+func newHTMLData(indexFile *repo.IndexFile, repoURL string) *htmlData {
+	data := &htmlData{RepoURL: repoURL, Generated: indexFile.Generated}
+	for name, entries := range indexFile.Entries {
+		versions := append(repo.ChartVersions(nil), entries...)
+		sort.SliceStable(versions, func(i, j int) bool {
+			vi, erri := semver.NewVersion(versions[i].Version)
+			vj, errj := semver.NewVersion(versions[j].Version)
+			if erri != nil || errj != nil {
+				return versions[i].Version > versions[j].Version
+			}
+			return vj.LessThan(vi)
+		})
+		chart := htmlChart{Name: name}
+		for _, version := range versions {
+			v := htmlVersion{ChartVersion: version}
+			if len(version.URLs) > 0 {
+				v.URL = version.URLs[0]
+			}
+			chart.Versions = append(chart.Versions, v)
+		}
+		if len(versions) > 0 {
+			chart.Description = versions[0].Description
+		}
+		data.Charts = append(data.Charts, chart)
+	}
+	sort.Slice(data.Charts, func(i, j int) bool {
+		return data.Charts[i].Name < data.Charts[j].Name
+	})
+	return data
+}
+
+// htmlFile returns the path of the landing page written next to the index file.
+func (r *Releaser) htmlFile() string {
+	return filepath.Join(filepath.Dir(r.config.IndexPath), htmlFileName)
+}
+
+// writeHTML renders the landing page of the index with the configured
+// template, or the default one, next to the index file.
+func (r *Releaser) writeHTML(indexFile *repo.IndexFile) error {
+	text := defaultHTMLTemplate
+	if r.config.HTMLTemplate != "" {
+		content, err := ioutil.ReadFile(r.config.HTMLTemplate)
+		if err != nil {
+			return err
+		}
+		text = string(content)
+	}
+	tmpl, err := template.New(htmlFileName).Funcs(sprig.HtmlFuncMap()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return errors.Wrap(err, "error parsing html template")
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, newHTMLData(indexFile, r.config.ChartsRepo)); err != nil {
+		return errors.Wrap(err, "error rendering html template")
+	}
+	return ioutil.WriteFile(r.htmlFile(), buffer.Bytes(), 0644)
+}
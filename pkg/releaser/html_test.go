@@ -0,0 +1,109 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/helm/chart-releaser/pkg/config"
+)
+
+func TestReleaser_UpdateIndexFileGenerateHTML(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.yaml")
+	fakeStorage := &FakeStorage{}
+	r := &Releaser{
+		config: &config.Options{
+			IndexPath:    indexPath,
+			PackagePath:  "testdata/release-packages",
+			ChartsRepo:   "https://owner.github.io/repo",
+			GenerateHTML: true,
+		},
+		httpClient: &MockClient{http.StatusNotFound, ""},
+		storage:    fakeStorage,
+	}
+
+	update, err := r.UpdateIndexFile(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, update)
+	html, err := ioutil.ReadFile(filepath.Join(filepath.Dir(indexPath), "index.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(html), `<h2 id="test-chart">test-chart</h2>`)
+	assert.Contains(t, string(html), "helm repo add &lt;name&gt; https://owner.github.io/repo")
+	assert.Contains(t, string(html), ">0.1.0</a>")
+	assert.Equal(t, "text/html; charset=utf-8", fakeStorage.uploads["index.html"])
+}
+
+func TestReleaser_writeHTML(t *testing.T) {
+	indexFile := repo.NewIndexFile()
+	for _, version := range []string{"1.9.0", "1.10.0", "1.2.0"} {
+		assert.NoError(t, indexFile.MustAdd(&chart.Metadata{APIVersion: chart.APIVersionV2, Name: "redis", Version: version, Description: "Redis <in-memory> store"},
+			"redis-"+version+".tgz", "https://example.com/charts", "sha256:0000"))
+	}
+	assert.NoError(t, indexFile.MustAdd(&chart.Metadata{APIVersion: chart.APIVersionV2, Name: "nginx", Version: "0.1.0"},
+		"nginx-0.1.0.tgz", "https://example.com/charts", "sha256:0000"))
+
+	dir := t.TempDir()
+	htmlTemplate := filepath.Join(dir, "index.html.tmpl")
+	assert.NoError(t, ioutil.WriteFile(htmlTemplate, []byte(
+		`{{ range .Charts }}{{ .Name }}: {{ .Description }}:{{ range .Versions }} {{ .Version }}{{ end }}
+{{ end }}`), 0644))
+
+	tests := []struct {
+		name         string
+		htmlTemplate string
+		expected     string
+		error        bool
+	}{
+		{
+			"custom-template",
+			htmlTemplate,
+			"nginx: : 0.1.0\nredis: Redis &lt;in-memory&gt; store: 1.10.0 1.9.0 1.2.0\n",
+			false,
+		},
+		{
+			"missing-template",
+			filepath.Join(dir, "missing.tmpl"),
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Releaser{
+				config: &config.Options{
+					IndexPath:    filepath.Join(dir, "index.yaml"),
+					HTMLTemplate: tt.htmlTemplate,
+				},
+			}
+			err := r.writeHTML(indexFile)
+			if tt.error {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			html, err := ioutil.ReadFile(filepath.Join(dir, "index.html"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(html))
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"sort"
+
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// IndexDiff summarizes the differences between two versions of an index, e.g.
+// of the index in the charts repository and of the index about to replace it.
+// Each list is sorted by chart name and then in the order of the sorted index.
+type IndexDiff struct {
+	// Added are the chart versions which are only part of the new index.
+	Added []*repo.ChartVersion
+	// Removed are the chart versions which are only part of the old index.
+	Removed []*repo.ChartVersion
+	// Changed are the chart versions which are part of both indexes, but
+	// with a different digest or different URLs.
+	Changed []ChangedVersion
+}
+
+// ChangedVersion is a chart version whose entry differs between two versions
+// of an index.
+type ChangedVersion struct {
+	Old *repo.ChartVersion
+	New *repo.ChartVersion
+}
+
+// Empty reports whether the indexes contain the same chart versions.
+func (d IndexDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffIndex compares the chart versions of the old and the new index. Either
+// index may be nil, which is treated as an empty index.
+func DiffIndex(old, new *repo.IndexFile) IndexDiff {
+	oldEntries := indexEntries(old)
+	newEntries := indexEntries(new)
+
+	names := make([]string, 0, len(oldEntries)+len(newEntries))
+	for name := range oldEntries {
+		names = append(names, name)
+	}
+	for name := range newEntries {
+		if _, ok := oldEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diff IndexDiff
+	for _, name := range names {
+		previous := make(map[string]*repo.ChartVersion, len(oldEntries[name]))
+		for _, entry := range oldEntries[name] {
+			previous[entry.Version] = entry
+		}
+		current := make(map[string]bool, len(newEntries[name]))
+		for _, entry := range newEntries[name] {
+			current[entry.Version] = true
+			oldEntry, ok := previous[entry.Version]
+			switch {
+			case !ok:
+				diff.Added = append(diff.Added, entry)
+			case oldEntry.Digest != entry.Digest || !equalStrings(oldEntry.URLs, entry.URLs):
+				diff.Changed = append(diff.Changed, ChangedVersion{Old: oldEntry, New: entry})
+			}
+		}
+		for _, entry := range oldEntries[name] {
+			if !current[entry.Version] {
+				diff.Removed = append(diff.Removed, entry)
+			}
+		}
+	}
+	return diff
+}
+
+func indexEntries(indexFile *repo.IndexFile) map[string]repo.ChartVersions {
+	if indexFile == nil {
+		return nil
+	}
+	return indexFile.Entries
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// printIndexDiff prints the changes of the index, one per line.
+func (r *Releaser) printIndexDiff(diff IndexDiff) {
+	for _, entry := range diff.Added {
+		url := firstURL(entry)
+		r.printDryRun("add-to-index", logging.Fields{"chart": entry.Name, "version": entry.Version, "url": url, "digest": entry.Digest},
+			"index add chart=%s version=%s url=%s digest=%s", entry.Name, entry.Version, url, entry.Digest)
+	}
+	for _, changed := range diff.Changed {
+		entry, url := changed.New, firstURL(changed.New)
+		r.printDryRun("change-in-index", logging.Fields{"chart": entry.Name, "version": entry.Version, "url": url, "digest": entry.Digest, "old_digest": changed.Old.Digest},
+			"index change chart=%s version=%s url=%s digest=%s old_digest=%s", entry.Name, entry.Version, url, entry.Digest, changed.Old.Digest)
+	}
+	for _, entry := range diff.Removed {
+		r.printDryRun("remove-from-index", logging.Fields{"chart": entry.Name, "version": entry.Version},
+			"index remove chart=%s version=%s", entry.Name, entry.Version)
+	}
+}
+
+// firstURL returns the first URL of the chart version, or "" if it has none.
+func firstURL(entry *repo.ChartVersion) string {
+	if len(entry.URLs) > 0 {
+		return entry.URLs[0]
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+func testIndex(entries ...*repo.ChartVersion) *repo.IndexFile {
+	indexFile := repo.NewIndexFile()
+	for _, entry := range entries {
+		indexFile.Entries[entry.Name] = append(indexFile.Entries[entry.Name], entry)
+	}
+	indexFile.SortEntries()
+	return indexFile
+}
+
+func testEntry(name, version, digest, url string) *repo.ChartVersion {
+	return &repo.ChartVersion{
+		Metadata: &chart.Metadata{Name: name, Version: version},
+		Digest:   digest,
+		URLs:     []string{url},
+	}
+}
+
+func diffSummary(diff IndexDiff) map[string][]string {
+	summary := map[string][]string{}
+	for _, entry := range diff.Added {
+		summary["added"] = append(summary["added"], entry.Name+"-"+entry.Version)
+	}
+	for _, entry := range diff.Removed {
+		summary["removed"] = append(summary["removed"], entry.Name+"-"+entry.Version)
+	}
+	for _, changed := range diff.Changed {
+		summary["changed"] = append(summary["changed"], changed.New.Name+"-"+changed.New.Version)
+	}
+	return summary
+}
+
+func TestDiffIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		old  *repo.IndexFile
+		new  *repo.IndexFile
+		want map[string][]string
+	}{
+		{
+			name: "unchanged",
+			old:  testIndex(testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz")),
+			new:  testIndex(testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz")),
+			want: map[string][]string{},
+		},
+		{
+			name: "added",
+			old:  testIndex(testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz")),
+			new: testIndex(
+				testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz"),
+				testEntry("a", "1.1.0", "2", "https://x/a-1.1.0.tgz"),
+				testEntry("b", "0.1.0", "3", "https://x/b-0.1.0.tgz"),
+			),
+			want: map[string][]string{"added": {"a-1.1.0", "b-0.1.0"}},
+		},
+		{
+			name: "removed",
+			old: testIndex(
+				testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz"),
+				testEntry("b", "0.1.0", "3", "https://x/b-0.1.0.tgz"),
+			),
+			new:  testIndex(testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz")),
+			want: map[string][]string{"removed": {"b-0.1.0"}},
+		},
+		{
+			name: "changed",
+			old: testIndex(
+				testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz"),
+				testEntry("b", "0.1.0", "3", "https://x/b-0.1.0.tgz"),
+			),
+			new: testIndex(
+				testEntry("a", "1.0.0", "2", "https://x/a-1.0.0.tgz"),
+				testEntry("b", "0.1.0", "3", "https://y/b-0.1.0.tgz"),
+			),
+			want: map[string][]string{"changed": {"a-1.0.0", "b-0.1.0"}},
+		},
+		{
+			name: "no-old-index",
+			new:  testIndex(testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz")),
+			want: map[string][]string{"added": {"a-1.0.0"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffIndex(tt.old, tt.new)
+			assert.Equal(t, tt.want, diffSummary(diff))
+			assert.Equal(t, len(tt.want) == 0, diff.Empty())
+		})
+	}
+
+	diff := DiffIndex(
+		testIndex(testEntry("a", "1.0.0", "1", "https://x/a-1.0.0.tgz")),
+		testIndex(testEntry("a", "1.0.0", "2", "https://x/a-1.0.0.tgz")),
+	)
+	assert.Equal(t, "1", diff.Changed[0].Old.Digest)
+	assert.Equal(t, "2", diff.Changed[0].New.Digest)
+}
+
+func TestReleaser_UpdateIndexFileDryRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	remoteIndex := filepath.Join(dir, "remote.yaml")
+	assert.NoError(t, testIndex(testEntry("old-chart", "1.0.0", "1", "https://x/old-chart-1.0.0.tgz")).WriteFile(remoteIndex, 0644))
+
+	var out bytes.Buffer
+	logger, err := logging.New(logging.FormatJSON, &out)
+	assert.NoError(t, err)
+	r := &Releaser{
+		config: &config.Options{
+			IndexPath:    filepath.Join(dir, "index.yaml"),
+			PackagePath:  "testdata/release-packages",
+			DryRun:       true,
+			RebuildIndex: true,
+		},
+		github:     new(FakeGitHub),
+		httpClient: &MockClient{http.StatusOK, remoteIndex},
+		logger:     logger,
+	}
+	update, err := r.UpdateIndexFile(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, update)
+	assert.NoFileExists(t, r.config.IndexPath)
+
+	actions := map[string][]string{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var event map[string]interface{}
+		assert.NoError(t, decoder.Decode(&event))
+		if dryRun, _ := event["dry_run"].(bool); dryRun {
+			action, _ := event["action"].(string)
+			actions[action] = append(actions[action], event["chart"].(string)+"-"+event["version"].(string))
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"add-to-index":      {"test-chart-0.1.0"},
+		"remove-from-index": {"old-chart-1.0.0"},
+	}, actions)
+}
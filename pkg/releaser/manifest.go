@@ -0,0 +1,103 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// manifestFileName is the name of the manifest written next to the index.
+const manifestFileName = "manifest.json"
+
+// Manifest is a machine-readable inventory of the chart versions of an index.
+type Manifest struct {
+	Charts []ManifestEntry `json:"charts"`
+}
+
+// ManifestEntry is a chart version listed in a manifest.
+type ManifestEntry struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Digest  string `json:"digest"`
+	URL     string `json:"url"`
+}
+
+// newManifest returns the manifest of the index, sorted by chart name and
+// version, so that it only changes if the chart versions change.
+func newManifest(indexFile *repo.IndexFile) *Manifest {
+	manifest := &Manifest{Charts: []ManifestEntry{}}
+	for name, entries := range indexFile.Entries {
+		for _, entry := range entries {
+			var url string
+			if len(entry.URLs) > 0 {
+				url = entry.URLs[0]
+			}
+			manifest.Charts = append(manifest.Charts, ManifestEntry{
+				Name:    name,
+				Version: entry.Version,
+				Digest:  entry.Digest,
+				URL:     url,
+			})
+		}
+	}
+	sort.Slice(manifest.Charts, func(i, j int) bool {
+		ci, cj := manifest.Charts[i], manifest.Charts[j]
+		if ci.Name != cj.Name {
+			return ci.Name < cj.Name
+		}
+		vi, erri := semver.NewVersion(ci.Version)
+		vj, errj := semver.NewVersion(cj.Version)
+		if erri != nil || errj != nil {
+			return ci.Version < cj.Version
+		}
+		return vi.LessThan(vj)
+	})
+	return manifest
+}
+
+// manifestFiles returns the paths of the checksum file and the manifest
+// written next to the index file.
+func (r *Releaser) manifestFiles() (string, string) {
+	return r.config.IndexPath + ".sha256", filepath.Join(filepath.Dir(r.config.IndexPath), manifestFileName)
+}
+
+// writeManifest writes the SHA-256 checksum of the index file, in the format
+// of sha256sum, and the manifest of the index next to the index file.
+func (r *Releaser) writeManifest(indexFile *repo.IndexFile) error {
+	checksumFile, manifestFile := r.manifestFiles()
+
+	digest, err := provenance.DigestFile(r.config.IndexPath)
+	if err != nil {
+		return err
+	}
+	checksum := fmt.Sprintf("%s  %s\n", digest, filepath.Base(r.config.IndexPath))
+	if err := ioutil.WriteFile(checksumFile, []byte(checksum), 0644); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(newManifest(indexFile), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(manifestFile, append(data, '\n'), 0644)
+}
@@ -0,0 +1,94 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/helm/chart-releaser/pkg/config"
+)
+
+func TestReleaser_UpdateIndexFileWritesManifest(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "index.yaml")
+	fakeStorage := &FakeStorage{}
+	r := &Releaser{
+		config: &config.Options{
+			IndexPath:     indexPath,
+			PackagePath:   "testdata/release-packages",
+			WriteManifest: true,
+		},
+		httpClient: &MockClient{http.StatusNotFound, ""},
+		storage:    fakeStorage,
+	}
+
+	update, err := r.UpdateIndexFile(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, update)
+	assert.Equal(t, "text/plain", fakeStorage.uploads["index.yaml.sha256"])
+	assert.Equal(t, "application/json", fakeStorage.uploads["manifest.json"])
+
+	digest, err := provenance.DigestFile(indexPath)
+	assert.NoError(t, err)
+	checksum, err := ioutil.ReadFile(indexPath + ".sha256")
+	assert.NoError(t, err)
+	assert.Equal(t, digest+"  index.yaml\n", string(checksum))
+
+	packageDigest, err := provenance.DigestFile("testdata/release-packages/test-chart-0.1.0.tgz")
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	assert.NoError(t, err)
+	var manifest Manifest
+	assert.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, Manifest{Charts: []ManifestEntry{
+		{
+			Name:    "test-chart",
+			Version: "0.1.0",
+			Digest:  packageDigest,
+			URL:     "https://bucket.example.com/charts/test-chart-0.1.0.tgz",
+		},
+	}}, manifest)
+}
+
+func TestNewManifest(t *testing.T) {
+	indexFile, err := repo.LoadIndexFile("testdata/repo/index.yaml")
+	assert.NoError(t, err)
+	for _, version := range []string{"0.10.0", "0.2.0", "0.2.0-rc.1"} {
+		assert.NoError(t, indexFile.MustAdd(&chart.Metadata{APIVersion: "v2", Name: "a-chart", Version: version}, "a-chart-"+version+".tgz", "https://example.com", "sha256:"+version))
+	}
+
+	var versions []string
+	for _, entry := range newManifest(indexFile).Charts {
+		versions = append(versions, entry.Name+"-"+entry.Version)
+	}
+	assert.Equal(t, "a-chart-0.2.0-rc.1,a-chart-0.2.0,a-chart-0.10.0", strings.Join(versions[:3], ","))
+
+	first, err := json.Marshal(newManifest(indexFile))
+	assert.NoError(t, err)
+	second, err := json.Marshal(newManifest(indexFile))
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+}
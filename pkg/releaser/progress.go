@@ -0,0 +1,75 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import "helm.sh/helm/v3/pkg/chart"
+
+// Phase is a step of publishing a chart version.
+type Phase string
+
+const (
+	// PhasePackage is the packaging of a chart from the charts directory.
+	PhasePackage Phase = "package"
+	// PhaseRelease is the creation of the release of a chart package.
+	PhaseRelease Phase = "release"
+	// PhaseIndex is the addition of a chart package to the index.
+	PhaseIndex Phase = "index"
+)
+
+// ProgressEvent is reported before and after each phase of a chart version.
+type ProgressEvent struct {
+	Chart   string
+	Version string
+	Phase   Phase
+	// Done is false before and true after the phase.
+	Done bool
+	// Err is the error the phase failed with, if any. It is only set if Done
+	// is true.
+	Err error
+}
+
+// ProgressFunc is called with the progress of the releaser. Releases are
+// created concurrently if max-concurrency is greater than 1, so it must be
+// safe for concurrent use then.
+type ProgressFunc func(event ProgressEvent)
+
+// Option configures a Releaser.
+type Option func(*Releaser)
+
+// WithProgressFunc sets the function the progress of the releaser is reported
+// to, e.g. for showing a progress bar when embedding the releaser.
+func WithProgressFunc(f ProgressFunc) Option {
+	return func(r *Releaser) {
+		r.progressFunc = f
+	}
+}
+
+// progress reports a phase of the given chart version to the progress function,
+// if one is set, and records it for the summary of the run.
+func (r *Releaser) progress(phase Phase, metadata *chart.Metadata, done bool, err error) {
+	event := ProgressEvent{
+		Chart:   metadata.Name,
+		Version: metadata.Version,
+		Phase:   phase,
+		Done:    done,
+		Err:     err,
+	}
+	if r.stats != nil {
+		r.recordProgress(event)
+	}
+	if r.progressFunc != nil {
+		r.progressFunc(event)
+	}
+}
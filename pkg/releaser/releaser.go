@@ -15,19 +15,31 @@
 package releaser
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/Songmu/retry"
+	"github.com/Masterminds/semver/v3"
+	"github.com/Masterminds/sprig/v3"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/net/http/httpproxy"
 
 	"text/template"
 
@@ -35,13 +47,24 @@ import (
 
 	"github.com/pkg/errors"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 
 	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/cosign"
 
 	"helm.sh/helm/v3/pkg/provenance"
 	"helm.sh/helm/v3/pkg/repo"
 
+	"github.com/helm/chart-releaser/pkg/git"
+	"github.com/helm/chart-releaser/pkg/gitea"
 	"github.com/helm/chart-releaser/pkg/github"
+	"github.com/helm/chart-releaser/pkg/gitlab"
+	"github.com/helm/chart-releaser/pkg/logging"
+	"github.com/helm/chart-releaser/pkg/packager"
+	"github.com/helm/chart-releaser/pkg/registry"
+	"github.com/helm/chart-releaser/pkg/sbom"
+	"github.com/helm/chart-releaser/pkg/storage"
+	"github.com/helm/chart-releaser/pkg/version"
 )
 
 // GitHub contains the functions necessary for interacting with GitHub release
@@ -49,23 +72,88 @@ import (
 type GitHub interface {
 	CreateRelease(ctx context.Context, input *github.Release) error
 	GetRelease(ctx context.Context, tag string) (*github.Release, error)
+	GetReleaseByID(ctx context.Context, id int64) (*github.Release, error)
+	GetCommitSHA(ctx context.Context, ref string) (string, error)
+	ListReleases(ctx context.Context) ([]*github.Release, error)
+	DeleteRelease(ctx context.Context, release *github.Release) error
+	DeleteTag(ctx context.Context, tag string) error
+	PublishRelease(ctx context.Context, release *github.Release) error
+	UploadAssets(ctx context.Context, release *github.Release, assets []*github.Asset) error
 	CreatePullRequest(owner string, repo string, message string, head string, base string) (string, error)
 }
 
+// permissionChecker is implemented by the clients of providers which can check
+// up front that the token may create releases.
+type permissionChecker interface {
+	CheckPermissions(ctx context.Context) error
+}
+
 type HttpClient interface {
-	Get(url string) (*http.Response, error)
+	Get(ctx context.Context, url string, header http.Header) (*http.Response, error)
+	Post(ctx context.Context, url string, contentType string, body io.Reader) (*http.Response, error)
 }
 
 type Git interface {
 	AddWorktree(workingDir string, committish string) (string, error)
 	RemoveWorktree(workingDir string, path string) error
+	Clone(repoURL string, token string, committish string) (string, error)
 	Add(workingDir string, args ...string) error
 	Commit(workingDir string, message string) error
 	Push(workingDir string, args ...string) error
 	GetPushURL(remote string, token string) (string, error)
+	GetRemoteURL(remote string) (string, error)
+	ChangedFiles(workingDir string, since string) ([]string, error)
+}
+
+// Registry contains the functions necessary for pushing chart packages to an
+// OCI registry
+type Registry interface {
+	Login(registryURL string, username string, password string) error
+	Exists(registryURL string, name string, version string) (bool, error)
+	Push(chartPackage string, registryURL string) error
+}
+
+// Cosigner signs chart packages with sigstore
+type Cosigner interface {
+	SignBlob(file string, signature string, bundle string) error
+}
+
+// SBOMGenerator writes a software bill of materials for a chart package
+type SBOMGenerator interface {
+	Generate(chartPackage string, sbom string) error
 }
 
-type DefaultHttpClient struct{}
+// DefaultHttpClient downloads files with a timeout. Redirects are followed,
+// but not from HTTPS to plain HTTP.
+type DefaultHttpClient struct {
+	client *http.Client
+	// header is set on every request, e.g. a User-Agent required by a proxy.
+	header http.Header
+}
+
+// NewDefaultHttpClient returns a DefaultHttpClient with the given timeout per
+// request
+func NewDefaultHttpClient(timeout time.Duration) *DefaultHttpClient {
+	return &DefaultHttpClient{
+		client: &http.Client{
+			Timeout:       timeout,
+			CheckRedirect: checkRedirect,
+		},
+	}
+}
+
+// checkRedirect limits the number of redirects and refuses to downgrade to
+// plain HTTP. Sensitive headers such as Authorization are dropped by
+// net/http when redirecting to another host.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return errors.Errorf("refusing redirect from %s to insecure %s", via[0].URL, req.URL)
+	}
+	return nil
+}
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyz0123456789")
 
@@ -73,8 +161,42 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
-func (c *DefaultHttpClient) Get(url string) (resp *http.Response, err error) {
-	return http.Get(url)
+func (c *DefaultHttpClient) Get(ctx context.Context, url string, header http.Header) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return c.client.Do(req)
+}
+
+func (c *DefaultHttpClient) Post(ctx context.Context, url string, contentType string, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.client.Do(req)
+}
+
+// errorList collects the errors of independent operations, e.g. the releases
+// of several packages, so that all of them can be reported at once.
+type errorList []error
+
+func (e errorList) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
 }
 
 type Releaser struct {
@@ -82,270 +204,3461 @@ type Releaser struct {
 	github     GitHub
 	httpClient HttpClient
 	git        Git
+	registry   Registry
+	cosigner   Cosigner
+	sbom       SBOMGenerator
+	storage    storage.Backend
+	logger     *logging.Logger
+
+	// mirrors receive a copy of the index, and of the chart packages added
+	// to it if mirror-packages is set, after it has been written.
+	mirrors []storage.Backend
+
+	progressFunc ProgressFunc
+
+	// urlTemplate computes the URLs of the chart packages added to the index
+	// instead of the download URLs if set.
+	urlTemplate *template.Template
+
+	// assetNameTemplate computes the names the chart packages are uploaded
+	// as instead of their file names if set.
+	assetNameTemplate *template.Template
+
+	// preReleaseHookTemplate computes the command run for every chart
+	// package before releasing it if set.
+	preReleaseHookTemplate *template.Template
+
+	// stats collects the timing of the phases for the summary of the run.
+	stats *runStats
+
+	// worktrees tracks the worktrees of the pages branch which have not been
+	// removed yet, see Cleanup.
+	worktrees *worktrees
 }
 
-func NewReleaser(config *config.Options, github GitHub, git Git) *Releaser {
-	return &Releaser{
-		config:     config,
-		github:     github,
-		httpClient: &DefaultHttpClient{},
-		git:        git,
+// NewReleaser returns a Releaser using the client of the configured provider.
+// A *git.Git is not modified, the releaser runs git with a copy of it
+// configured from config. A nil g runs git with the defaults.
+func NewReleaser(config *config.Options, g Git, opts ...Option) (*Releaser, error) {
+	// The token and base URLs are resolved below, work on a copy to leave the
+	// caller's options untouched.
+	options := *config
+	options.MirrorBaseURLs = append([]string(nil), config.MirrorBaseURLs...)
+	config = &options
+
+	logger, err := logging.New(config.LogFormat, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ResolveToken(); err != nil {
+		return nil, err
 	}
-}
 
-// UpdateIndexFile updates the index.yaml file for a given Git repo
-func (r *Releaser) UpdateIndexFile() (bool, error) {
-	// if path doesn't end with index.yaml we can try and fix it
-	if filepath.Base(r.config.IndexPath) != "index.yaml" {
-		// if path is a directory then add index.yaml
-		if stat, err := os.Stat(r.config.IndexPath); err == nil && stat.IsDir() {
-			r.config.IndexPath = filepath.Join(r.config.IndexPath, "index.yaml")
-			// otherwise error out
-		} else {
-			fmt.Printf("path (%s) should be a directory or a file called index.yaml\n", r.config.IndexPath)
-			os.Exit(1)
-		}
+	if err := validateMakeReleaseLatest(config.MakeReleaseLatest); err != nil {
+		return nil, err
 	}
 
-	var indexFile *repo.IndexFile
+	switch config.GitPushMode {
+	case "", git.PushModeHTTPS, git.PushModeSSH:
+	default:
+		return nil, errors.Errorf("invalid value %q for git-push-mode, must be one of: %s, %s", config.GitPushMode, git.PushModeHTTPS, git.PushModeSSH)
+	}
 
-	resp, err := r.httpClient.Get(fmt.Sprintf("%s/index.yaml", r.config.ChartsRepo))
+	header, err := requestHeader(config)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
+	var urlTemplate *template.Template
+	if config.URLTemplate != "" {
+		if urlTemplate, err = parseTemplate("url", config.URLTemplate, config.TemplateEnv); err != nil {
+			return nil, errors.Wrap(err, "error parsing url template")
+		}
+	}
+
+	var assetNameTemplate *template.Template
+	if config.AssetNameTemplate != "" {
+		if assetNameTemplate, err = parseTemplate("asset-name", config.AssetNameTemplate, config.TemplateEnv); err != nil {
+			return nil, errors.Wrap(err, "error parsing asset name template")
+		}
+	}
+
+	var preReleaseHookTemplate *template.Template
+	if config.PreReleaseHook != "" {
+		if preReleaseHookTemplate, err = parseTemplate("pre-release-hook", config.PreReleaseHook, config.TemplateEnv); err != nil {
+			return nil, errors.Wrap(err, "error parsing pre-release hook template")
+		}
+	}
+
+	if config.BaseURL, err = renderBaseURL("base-url", config.BaseURL, config.TemplateEnv); err != nil {
+		return nil, err
+	}
+	for i, mirror := range config.MirrorBaseURLs {
+		if config.MirrorBaseURLs[i], err = renderBaseURL("mirror-base-urls", mirror, config.TemplateEnv); err != nil {
+			return nil, err
+		}
+	}
+
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
 
-	if resp.StatusCode == http.StatusOK {
-		out, err := os.Create(r.config.IndexPath)
+	var client GitHub
+	var tokenUser string
+	switch config.Provider {
+	case "", "github":
+		client = github.NewClient(config.Owner, config.GitRepo, config.Token, config.GitBaseURL, config.GitUploadURL,
+			github.WithRetries(config.MaxRetries, config.RetryDelay),
+			github.WithRateLimitPause(config.RateLimitPause),
+			github.WithSkipExisting(config.SkipExisting),
+			github.WithHeader(header),
+			github.WithTransport(transport),
+			github.WithLogger(logger))
+	case "gitlab":
+		baseURL := config.GitBaseURL
+		if baseURL == "" || baseURL == github.DefaultBaseURL {
+			baseURL = gitlab.DefaultBaseURL
+		}
+		c, err := gitlab.NewClient(config.Owner, config.GitRepo, config.Token, baseURL)
 		if err != nil {
-			return false, err
+			return nil, err
+		}
+		client = c
+		tokenUser = "oauth2"
+	case "gitea":
+		if config.GitBaseURL == "" || config.GitBaseURL == github.DefaultBaseURL {
+			return nil, errors.New("git-base-url is required for gitea, e.g. https://gitea.example.com/api/v1/")
 		}
-		defer out.Close()
+		c, err := gitea.NewClient(config.Owner, config.GitRepo, config.Token, config.GitBaseURL,
+			gitea.WithHeader(header),
+			gitea.WithTransport(transport))
+		if err != nil {
+			return nil, err
+		}
+		client = c
+		tokenUser = "oauth2"
+	default:
+		return nil, errors.Errorf("unknown provider %q, must be one of: github, gitlab, gitea", config.Provider)
+	}
+	g = configureGit(g, config, tokenUser, logger)
 
-		_, err = io.Copy(out, resp.Body)
+	var backend storage.Backend
+	if config.StorageBackend != "" && config.UnstableIndexPath != "" {
+		return nil, errors.New("unstable-index-path is not supported with a storage backend")
+	}
+	switch config.StorageBackend {
+	case "":
+	case "s3":
+		b, err := storage.NewS3(config.StorageBucket, config.StoragePrefix, config.S3Region, config.ChartsRepo, logger)
 		if err != nil {
-			return false, err
+			return nil, err
+		}
+		backend = b
+	case "gcs":
+		b, err := storage.NewGCS(config.StorageBucket, config.StoragePrefix, config.ChartsRepo, logger)
+		if err != nil {
+			return nil, err
 		}
+		backend = b
+	default:
+		return nil, errors.Errorf("unknown storage backend %q, must be one of: s3, gcs", config.StorageBackend)
+	}
 
-		fmt.Printf("Using existing index at %s\n", r.config.IndexPath)
-		indexFile, err = repo.LoadIndexFile(r.config.IndexPath)
+	if len(config.IndexMirrors) > 0 && config.UnstableIndexPath != "" {
+		return nil, errors.New("unstable-index-path is not supported with index mirrors")
+	}
+	var mirrors []storage.Backend
+	for _, mirrorURL := range config.IndexMirrors {
+		m, err := storage.Open(mirrorURL, config.S3Region, logger)
 		if err != nil {
-			return false, err
+			return nil, errors.Wrap(err, "error opening index mirror")
 		}
-	} else {
-		fmt.Printf("UpdateIndexFile new index at %s\n", r.config.IndexPath)
-		indexFile = repo.NewIndexFile()
+		mirrors = append(mirrors, m)
+	}
+
+	httpClient := NewDefaultHttpClient(time.Minute)
+	httpClient.client.Transport = transport
+	httpClient.header = header
+
+	r := &Releaser{
+		config:      config,
+		github:      client,
+		httpClient:  httpClient,
+		git:         g,
+		registry:    &registry.Registry{Logger: logger},
+		cosigner:    &cosign.Cosign{Logger: logger},
+		sbom:        &sbom.Generator{},
+		storage:     backend,
+		mirrors:     mirrors,
+		logger:      logger,
+		urlTemplate: urlTemplate,
+		stats:       newRunStats(),
+		worktrees:   &worktrees{},
+
+		assetNameTemplate:      assetNameTemplate,
+		preReleaseHookTemplate: preReleaseHookTemplate,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// validateMakeReleaseLatest makes sure that make-release-latest is one of the
+// values GitHub accepts for make_latest, or empty for GitHub's default.
+func validateMakeReleaseLatest(makeLatest string) error {
+	switch makeLatest {
+	case "", "true", "false", "legacy":
+		return nil
+	default:
+		return errors.Errorf("invalid value %q for make-release-latest, must be one of: true, false, legacy", makeLatest)
+	}
+}
+
+// configureGit returns a copy of g with the git options of config, the token
+// user of the provider, unless it is empty, and the logger of the releaser.
+// Other implementations of Git are returned as they are.
+func configureGit(g Git, config *config.Options, tokenUser string, logger *logging.Logger) Git {
+	if g == nil {
+		g = &git.Git{}
+	}
+	base, ok := g.(*git.Git)
+	if !ok {
+		return g
+	}
+	if tokenUser == "" {
+		tokenUser = base.TokenUser
+	}
+	return &git.Git{
+		TokenUser:   tokenUser,
+		PushMode:    config.GitPushMode,
+		UserName:    config.GitUserName,
+		UserEmail:   config.GitUserEmail,
+		SignCommits: config.SignCommits,
+		SigningKey:  config.CommitSigningKey,
+		WorktreeDir: config.WorktreeDir,
+		Logger:      logger,
+	}
+}
+
+// WithLogger makes the releaser log to out in the configured log format
+// instead of to stdout, the default, e.g. for capturing the log when embedding
+// the releaser. This includes the output of the git, helm and cosign commands
+// run by the releaser.
+func WithLogger(out io.Writer) Option {
+	return func(r *Releaser) {
+		r.logger.SetOutput(out)
+	}
+}
+
+// requestHeader returns the headers sent with the requests to GitHub and the
+// downloads of the index and chart packages: the user agent, which defaults to
+// chart-releaser/<version>, and the extra headers, given as "Name: value".
+func requestHeader(config *config.Options) (http.Header, error) {
+	header := http.Header{}
+	header.Set("User-Agent", version.UserAgent())
+	if config.UserAgent != "" {
+		header.Set("User-Agent", config.UserAgent)
+	}
+	for _, h := range config.ExtraHeaders {
+		name, value, ok := cutHeader(h)
+		if !ok {
+			return nil, errors.Errorf("invalid extra header %q, must be of the form 'Name: value'", h)
+		}
+		header.Add(name, value)
+	}
+	return header, nil
+}
+
+func cutHeader(h string) (string, string, bool) {
+	i := strings.Index(h, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	name := strings.TrimSpace(h[:i])
+	return name, strings.TrimSpace(h[i+1:]), name != "" && !strings.ContainsAny(name, " \t")
+}
+
+// newTransport returns the transport of the requests to GitHub and the
+// downloads of the index and chart packages. The configured proxy replaces
+// the one from the HTTPS_PROXY and HTTP_PROXY environment variables for both
+// schemes, and the configured no-proxy list replaces NO_PROXY.
+func newTransport(config *config.Options) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Proxy == "" && len(config.NoProxy) == 0 {
+		return transport, nil
 	}
 
-	// We have to explicitly glob for *.tgz files only. If GPG signing is enabled,
-	// this would also return *.tgz.prov files otherwise, which we don't want here.
-	chartPackages, err := filepath.Glob(r.config.PackagePath + "/*.tgz")
+	proxyConfig := httpproxy.FromEnvironment()
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, errors.Errorf("invalid proxy URL %q", config.Proxy)
+		}
+		proxyConfig.HTTPProxy = config.Proxy
+		proxyConfig.HTTPSProxy = config.Proxy
+	}
+	if len(config.NoProxy) > 0 {
+		proxyConfig.NoProxy = strings.Join(config.NoProxy, ",")
+	}
+	proxy := proxyConfig.ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	return transport, nil
+}
+
+// Publish publishes the draft releases of the chart packages in the package
+// path and then updates the index with them, as draft releases are left out of
+// the index until their assets can be downloaded.
+func (r *Releaser) Publish(ctx context.Context) (bool, error) {
+	chartPackages, _, err := r.getListOfIndexPackages()
 	if err != nil {
 		return false, err
 	}
-
-	var update bool
 	for _, chartPackage := range chartPackages {
 		ch, err := loader.LoadFile(chartPackage)
 		if err != nil {
 			return false, err
 		}
-		releaseName, err := r.computeReleaseName(ch)
+		tag, err := r.releaseTag(ch, chartPackage)
 		if err != nil {
 			return false, err
 		}
-
-		var release *github.Release
-		if err := retry.Retry(3, 3*time.Second, func() error {
-			rel, err := r.github.GetRelease(context.TODO(), releaseName)
-			if err != nil {
-				return err
-			}
-			release = rel
-			return nil
-		}); err != nil {
-			return false, err
+		release, err := r.github.GetRelease(ctx, tag)
+		if err != nil {
+			return false, errors.Wrapf(err, "error looking up release %s", tag)
 		}
-
-		for _, asset := range release.Assets {
-			downloadUrl, _ := url.Parse(asset.URL)
-			name := filepath.Base(downloadUrl.Path)
-			baseName := strings.TrimSuffix(name, filepath.Ext(name))
-			tagParts := r.splitPackageNameAndVersion(baseName)
-			packageName, packageVersion := tagParts[0], tagParts[1]
-			fmt.Printf("Found %s-%s.tgz\n", packageName, packageVersion)
-			if _, err := indexFile.Get(packageName, packageVersion); err != nil {
-				if err := r.addToIndexFile(indexFile, downloadUrl.String()); err != nil {
-					return false, err
-				}
-				update = true
-				break
-			}
+		if !release.Draft {
+			continue
+		}
+		if r.config.DryRun {
+			r.printDryRun("publish-release", logging.Fields{"tag": tag}, "publish release tag=%s", tag)
+			continue
+		}
+		if err := r.github.PublishRelease(ctx, release); err != nil {
+			return false, errors.Wrapf(err, "error publishing release %s", tag)
 		}
+		r.logger.Event("publish-release", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "tag": tag},
+			"Published release %s", tag)
 	}
+	return r.UpdateIndexFile(ctx)
+}
 
-	if !update {
-		fmt.Printf("Index %s did not change\n", r.config.IndexPath)
+// UpdateIndexFile updates the index.yaml file for a given Git repo. It is a
+// no-op if skip-index is set, for indexes maintained by other tools.
+func (r *Releaser) UpdateIndexFile(ctx context.Context) (bool, error) {
+	if r.config.SkipIndex {
+		r.logger.Event("skip-index", logging.Fields{"index": r.config.IndexPath}, "Skipping the update of %s, skip-index is set", r.config.IndexPath)
 		return false, nil
 	}
 
-	fmt.Printf("Updating index %s\n", r.config.IndexPath)
-	indexFile.SortEntries()
-
-	indexFile.Generated = time.Now()
-
-	if err := indexFile.WriteFile(r.config.IndexPath, 0644); err != nil {
-		return false, err
+	// if path doesn't end with index.yaml we can try and fix it
+	if filepath.Base(r.config.IndexPath) != "index.yaml" {
+		// if path is a directory then add index.yaml
+		if stat, err := os.Stat(r.config.IndexPath); err == nil && stat.IsDir() {
+			r.config.IndexPath = filepath.Join(r.config.IndexPath, "index.yaml")
+			// otherwise error out
+		} else {
+			r.logger.Printf("path (%s) should be a directory or a file called index.yaml", r.config.IndexPath)
+			os.Exit(1)
+		}
 	}
 
-	if !r.config.Push && !r.config.PR {
-		return true, nil
+	commitMessageTemplate, err := parseTemplate("commit-message", r.config.CommitMessageTemplate, r.config.TemplateEnv)
+	if err != nil {
+		return false, errors.Wrap(err, "error parsing commit message template")
 	}
 
-	worktree, err := r.git.AddWorktree("", r.config.Remote+"/"+r.config.PagesBranch)
+	chartPackages, digests, err := r.getListOfIndexPackages()
 	if err != nil {
 		return false, err
 	}
-	defer r.git.RemoveWorktree("", worktree) // nolint, errcheck
 
-	indexYamlPath := filepath.Join(worktree, "index.yaml")
-	if err := copyFile(r.config.IndexPath, indexYamlPath); err != nil {
-		return false, err
+	// Charts routed to the index of a sub-repository go into that index,
+	// which is maintained by a copy of the releaser configured for it.
+	var routes []string
+	routed := make(map[string][]string)
+	var unrouted []string
+	for _, chartPackage := range chartPackages {
+		route := r.indexRoute(chartPackage)
+		if route == "" {
+			unrouted = append(unrouted, chartPackage)
+			continue
+		}
+		if _, ok := routed[route]; !ok {
+			routes = append(routes, route)
+		}
+		routed[route] = append(routed[route], chartPackage)
 	}
-	if err := r.git.Add(worktree, indexYamlPath); err != nil {
-		return false, err
+	sort.Strings(routes)
+
+	// Prerelease versions go into the unstable index if there is one, which
+	// is maintained by a copy of the releaser configured for it as well.
+	channels := []*Releaser{r}
+	channelPackages := [][]string{unrouted}
+	if r.config.UnstableIndexPath != "" {
+		var stable, prerelease []string
+		for _, chartPackage := range unrouted {
+			if _, version, err := r.splitPackageNameAndVersion(strings.TrimSuffix(filepath.Base(chartPackage), ".tgz")); err == nil && isPrerelease(version) {
+				prerelease = append(prerelease, chartPackage)
+			} else {
+				stable = append(stable, chartPackage)
+			}
+		}
+		channels = append(channels, r.unstableReleaser())
+		channelPackages = [][]string{stable, prerelease}
 	}
-	if err := r.git.Commit(worktree, "Update index.yaml"); err != nil {
-		return false, err
+	for _, route := range routes {
+		channels = append(channels, r.routedReleaser(route))
+		channelPackages = append(channelPackages, routed[route])
 	}
 
-	pushURL, err := r.git.GetPushURL(r.config.Remote, r.config.Token)
-	if err != nil {
-		return false, err
+	var updates []*indexUpdate
+	for i, channel := range channels {
+		u, err := channel.updateIndex(ctx, channelPackages[i], digests)
+		if err != nil {
+			return false, err
+		}
+		if u != nil {
+			updates = append(updates, u)
+		}
+	}
+	if len(updates) == 0 {
+		return false, nil
 	}
 
-	if r.config.Push {
-		fmt.Printf("Pushing to branch %q\n", r.config.PagesBranch)
-		if err := r.git.Push(worktree, pushURL, "HEAD:refs/heads/"+r.config.PagesBranch); err != nil {
-			return false, err
+	if r.config.DryRun {
+		for _, u := range updates {
+			u.releaser.printIndexDiff(DiffIndex(u.previous, u.indexFile))
 		}
-	} else if r.config.PR {
-		branch := fmt.Sprintf("chart-releaser-%s", randomString(16))
+		return true, nil
+	}
 
-		fmt.Printf("Pushing to branch %q\n", branch)
-		if err := r.git.Push(worktree, pushURL, "HEAD:refs/heads/"+branch); err != nil {
-			return false, err
+	var added []*repo.ChartVersion
+	for _, u := range updates {
+		if r.config.MirrorPackages {
+			if err := u.releaser.mirrorPackages(ctx, u); err != nil {
+				return false, err
+			}
 		}
-		fmt.Printf("Creating pull request against branch %q\n", r.config.PagesBranch)
-		prURL, err := r.github.CreatePullRequest(r.config.Owner, r.config.GitRepo, "Update index.yaml", branch, r.config.PagesBranch)
-		if err != nil {
+		if err := u.releaser.writeIndexFile(ctx, u.indexFile); err != nil {
 			return false, err
 		}
-		fmt.Println("Pull request created:", prURL)
+		added = append(added, addedVersions(u.published, u.indexFile)...)
 	}
 
+	message := "Update index.yaml"
+	if r.config.CommitMessageTemplate != "" {
+		if message, err = computeCommitMessage(commitMessageTemplate, added); err != nil {
+			return false, err
+		}
+	}
+	if err := r.pushIndexFiles(message, updates); err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
-func (r *Releaser) computeReleaseName(chart *chart.Chart) (string, error) {
-	tmpl, err := template.New("gotpl").Parse(r.config.ReleaseNameTemplate)
-	if err != nil {
-		return "", err
-	}
+// indexUpdate is an updated index which has yet to be written, together with
+// the releaser maintaining it.
+type indexUpdate struct {
+	releaser      *Releaser
+	indexFile     *repo.IndexFile
+	published     map[string]bool
+	pagesPackages []string
 
-	var buffer bytes.Buffer
-	if err := tmpl.Execute(&buffer, chart.Metadata); err != nil {
-		return "", err
-	}
+	// previous is the index before the update. It is only loaded in dry-run
+	// mode, for printing the changes.
+	previous *repo.IndexFile
 
-	releaseName := buffer.String()
-	return releaseName, nil
+	// chartPackages are the local chart packages the index was updated with.
+	chartPackages []string
 }
 
-func (r *Releaser) splitPackageNameAndVersion(pkg string) []string {
-	delimIndex := strings.LastIndex(pkg, "-")
-	return []string{pkg[0:delimIndex], pkg[delimIndex+1:]}
+// unstableReleaser returns a copy of the releaser which maintains the index of
+// prerelease chart versions at the unstable index path instead of the main
+// index. Its repository is served from the directory of the unstable index in
+// the GitHub Pages branch.
+func (r *Releaser) unstableReleaser() *Releaser {
+	pagesIndexPath := r.config.UnstablePagesIndexPath
+	if pagesIndexPath == "" {
+		pagesIndexPath = "unstable/index.yaml"
+	}
+	return r.channelReleaser(r.config.UnstableIndexPath, pagesIndexPath)
 }
 
-func (r *Releaser) addToIndexFile(indexFile *repo.IndexFile, url string) error {
-	arch := filepath.Join(r.config.PackagePath, filepath.Base(url))
+// routedReleaser returns a copy of the releaser which maintains the index of
+// a sub-repository at the given path of the GitHub Pages branch. It is kept
+// locally at the same path relative to the directory of the main index.
+func (r *Releaser) routedReleaser(pagesIndexPath string) *Releaser {
+	indexPath := filepath.Join(filepath.Dir(r.config.IndexPath), filepath.FromSlash(pagesIndexPath))
+	routed := r.channelReleaser(indexPath, pagesIndexPath)
+	routed.config.UnstableIndexPath = ""
+	routed.config.IndexRouting = nil
+	return routed
+}
 
-	// extract chart metadata
-	fmt.Printf("Extracting chart metadata from %s\n", arch)
-	c, err := loader.LoadFile(arch)
-	if err != nil {
-		return errors.Wrapf(err, "%s is not a helm chart package", arch)
+// indexRoute returns the path of the index in the GitHub Pages branch the
+// chart package is routed to by the longest prefix of its chart name in
+// index-routing, taken from its file name. It is empty for charts indexed in
+// the main index.
+func (r *Releaser) indexRoute(chartPackage string) string {
+	if len(r.config.IndexRouting) == 0 {
+		return ""
 	}
-	// calculate hash
-	fmt.Printf("Calculating Hash for %s\n", arch)
-	hash, err := provenance.DigestFile(arch)
+	name, _, err := r.splitPackageNameAndVersion(strings.TrimSuffix(filepath.Base(chartPackage), ".tgz"))
 	if err != nil {
-		return err
+		return ""
 	}
-
-	// remove url name from url as helm's index library
-	// adds it in during .Add
-	// there should be a better way to handle this :(
-	s := strings.Split(url, "/")
-	s = s[:len(s)-1]
-
-	// Add to index
-	if err := indexFile.MustAdd(c.Metadata, filepath.Base(arch), strings.Join(s, "/"), hash); err != nil {
-		return err
+	var route, longest string
+	for prefix, pagesIndexPath := range r.config.IndexRouting {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(longest) {
+			route, longest = pagesIndexPath, prefix
+		}
 	}
-	return nil
+	return route
 }
 
-// CreateReleases finds and uploads Helm chart packages to GitHub
-func (r *Releaser) CreateReleases() error {
-	packages, err := r.getListOfPackages(r.config.PackagePath)
-	if err != nil {
-		return err
+// channelReleaser returns a copy of the releaser which maintains the index at
+// the given local path and path of the GitHub Pages branch. Its repository is
+// served from the directory of the index in the GitHub Pages branch.
+func (r *Releaser) channelReleaser(indexPath string, pagesIndexPath string) *Releaser {
+	config := *r.config
+	config.IndexPath = indexPath
+	config.PagesIndexPath = pagesIndexPath
+	config.RemoteIndexURL = ""
+	if r.config.ChartsRepo != "" {
+		config.RemoteIndexURL = strings.TrimSuffix(r.config.ChartsRepo, "/") + "/" + pagesIndexPath
 	}
-
-	if len(packages) == 0 {
-		return errors.Errorf("No charts found at %s.\n", r.config.PackagePath)
+	if baseURL, dir := r.pagesBaseURL(), path.Dir(pagesIndexPath); baseURL != "" && dir != "." {
+		config.BaseURL = baseURL + "/" + dir
 	}
+	channel := *r
+	channel.config = &config
+	return &channel
+}
 
-	for _, p := range packages {
-		ch, err := loader.LoadFile(p)
+// updateIndex adds the chart packages to the existing index of the releaser.
+// It returns nil if the index did not change.
+func (r *Releaser) updateIndex(ctx context.Context, chartPackages []string, digests packageDigests) (*indexUpdate, error) {
+	var indexFile *repo.IndexFile
+
+	// In dry-run mode the existing index is downloaded to a temporary
+	// directory so that the index path is left untouched.
+	indexPath := r.config.IndexPath
+	if r.config.DryRun {
+		dir, err := ioutil.TempDir("", "chart-releaser-")
 		if err != nil {
-			return err
+			return nil, err
 		}
-		releaseName, err := r.computeReleaseName(ch)
+		defer os.RemoveAll(dir)
+		indexPath = filepath.Join(dir, "index.yaml")
+	}
+
+	exists, err := r.downloadIndexFile(ctx, indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		r.logger.Printf("Using existing index at %s", r.config.IndexPath)
+		indexFile, err = repo.LoadIndexFile(indexPath)
 		if err != nil {
-			return err
-		}
-		release := &github.Release{
-			Name:        releaseName,
-			Description: ch.Metadata.Description,
-			Assets: []*github.Asset{
-				{Path: p},
-			},
-			Commit: r.config.Commit,
+			return nil, err
 		}
-		provFile := fmt.Sprintf("%s.prov", p)
-		if _, err := os.Stat(provFile); err == nil {
-			asset := &github.Asset{Path: provFile}
-			release.Assets = append(release.Assets, asset)
+	} else {
+		r.logger.Printf("UpdateIndexFile new index at %s", r.config.IndexPath)
+		indexFile = repo.NewIndexFile()
+	}
+
+	published := indexVersions(indexFile)
+	var previous *repo.IndexFile
+	if r.config.DryRun && exists {
+		if previous, err = repo.LoadIndexFile(indexPath); err != nil {
+			return nil, err
 		}
-		if r.config.SkipExisting {
-			existingRelease, _ := r.github.GetRelease(context.TODO(), releaseName)
-			if existingRelease != nil {
-				continue
-			}
+	}
+
+	var existingContent []byte
+	if r.config.StableGenerated {
+		indexFile.SortEntries()
+		if existingContent, err = indexContent(indexFile); err != nil {
+			return nil, err
 		}
-		if err := r.github.CreateRelease(context.TODO(), release); err != nil {
-			return errors.Wrapf(err, "error creating GitHub release %s", releaseName)
+	}
+
+	var update bool
+	if r.config.RebuildIndex {
+		if update, err = r.dropRemoteEntries(indexFile, chartPackages); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
-}
+	var added bool
+	var pagesPackages []string
+	switch {
+	case r.storage != nil:
+		added, err = r.addStoredPackages(ctx, indexFile, chartPackages, digests)
+	case r.config.PackagesWithIndex:
+		pagesPackages, err = r.addPagesPackages(indexFile, chartPackages, digests)
+		added = len(pagesPackages) > 0
+	default:
+		added, err = r.addReleasedPackages(ctx, indexFile, chartPackages, digests)
+	}
+	if err != nil {
+		return nil, err
+	}
+	update = update || added
 
+	indexFile.SortEntries()
+	if r.config.StableGenerated && update {
+		content, err := indexContent(indexFile)
+		if err != nil {
+			return nil, err
+		}
+		update = !bytes.Equal(existingContent, content)
+	}
+
+	// Guard against publishing an index wiped by accident, e.g. because the
+	// package path was empty.
+	if versions := len(indexVersions(indexFile)); r.config.FailOnIndexShrink && !r.config.AllowIndexShrink && versions < len(published) {
+		return nil, errors.Errorf("index %s would shrink from %d to %d chart versions, use allow-index-shrink if this is intended",
+			r.config.IndexPath, len(published), versions)
+	}
+
+	if !update {
+		r.logger.Printf("Index %s did not change", r.config.IndexPath)
+		return nil, nil
+	}
+
+	r.logger.Printf("Updating index %s", r.config.IndexPath)
+
+	if indexFile.Generated, err = r.config.GeneratedTime(); err != nil {
+		return nil, err
+	}
+	return &indexUpdate{releaser: r, indexFile: indexFile, published: published, pagesPackages: pagesPackages, chartPackages: chartPackages, previous: previous}, nil
+}
+
+// writeIndexFile writes the index file, and the manifest, signature and
+// landing page if enabled, and uploads them to the storage backend and the
+// index mirrors if configured.
+func (r *Releaser) writeIndexFile(ctx context.Context, indexFile *repo.IndexFile) error {
+	if err := os.MkdirAll(filepath.Dir(r.config.IndexPath), 0755); err != nil {
+		return err
+	}
+	if err := indexFile.WriteFile(r.config.IndexPath, 0644); err != nil {
+		return err
+	}
+	if r.config.GzipIndex {
+		if err := r.writeCompressedIndex(); err != nil {
+			return errors.Wrap(err, "error writing compressed index")
+		}
+	}
+	if r.config.WriteManifest {
+		if err := r.writeManifest(indexFile); err != nil {
+			return errors.Wrap(err, "error writing manifest")
+		}
+	}
+	if r.config.SignIndex {
+		if err := r.signIndexFile(); err != nil {
+			return err
+		}
+	}
+	if r.config.GenerateHTML {
+		if err := r.writeHTML(indexFile); err != nil {
+			return errors.Wrap(err, "error writing landing page")
+		}
+	}
+
+	var backends []storage.Backend
+	if r.storage != nil {
+		backends = append(backends, r.storage)
+	}
+	backends = append(backends, r.mirrors...)
+	return r.uploadFiles(ctx, backends, r.indexObjects())
+}
+
+// compressedIndexFile returns the path of the gzipped copy of the index.
+func (r *Releaser) compressedIndexFile() string {
+	return r.config.IndexPath + ".gz"
+}
+
+// writeCompressedIndex writes a gzipped copy of the index file next to it.
+// The gzip header carries no modification time, so that the copy only
+// changes if the index does.
+func (r *Releaser) writeCompressedIndex() error {
+	content, err := ioutil.ReadFile(r.config.IndexPath)
+	if err != nil {
+		return err
+	}
+	var buffer bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	gz.Name = filepath.Base(r.config.IndexPath)
+	if _, err := gz.Write(content); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.compressedIndexFile(), buffer.Bytes(), 0644)
+}
+
+// storageObject is a local file uploaded to a storage backend.
+type storageObject struct {
+	name        string
+	file        string
+	contentType string
+}
+
+// indexObjects returns the index file and the files written next to it.
+func (r *Releaser) indexObjects() []storageObject {
+	objects := []storageObject{{"index.yaml", r.config.IndexPath, storage.ContentTypeIndex}}
+	if r.config.GzipIndex {
+		objects = append(objects, storageObject{"index.yaml.gz", r.compressedIndexFile(), storage.ContentTypeCompressedIndex})
+	}
+	if r.config.WriteManifest {
+		checksumFile, manifestFile := r.manifestFiles()
+		objects = append(objects,
+			storageObject{"index.yaml.sha256", checksumFile, storage.ContentTypeChecksum},
+			storageObject{manifestFileName, manifestFile, storage.ContentTypeManifest})
+	}
+	if r.config.SignIndex {
+		objects = append(objects, storageObject{"index.yaml.asc", r.indexSignatureFile(), storage.ContentTypeProvenance})
+	}
+	if r.config.GenerateHTML {
+		objects = append(objects, storageObject{htmlFileName, r.htmlFile(), storage.ContentTypeHTML})
+	}
+	return objects
+}
+
+// mirrorPackages uploads the chart packages added to the index of the update,
+// together with their provenance files, to the index mirrors.
+func (r *Releaser) mirrorPackages(ctx context.Context, u *indexUpdate) error {
+	added := make(map[string]bool)
+	for _, cv := range addedVersions(u.published, u.indexFile) {
+		added[cv.Name+"-"+cv.Version] = true
+	}
+	var objects []storageObject
+	for _, chartPackage := range u.chartPackages {
+		// the index entries carry the published names of the charts
+		ch, err := loader.LoadFile(chartPackage)
+		if err != nil {
+			return errors.Wrapf(err, "%s is not a helm chart package", chartPackage)
+		}
+		if !added[r.publishedName(ch.Metadata.Name)+"-"+ch.Metadata.Version] {
+			continue
+		}
+		// the mirror URLs of the index entries use the asset name as well
+		name, err := r.assetName(ch, chartPackage)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, storageObject{name, chartPackage, storage.ContentTypePackage})
+		provFile := fmt.Sprintf("%s.prov", chartPackage)
+		if _, err := os.Stat(provFile); err == nil {
+			objects = append(objects, storageObject{name + ".prov", provFile, storage.ContentTypeProvenance})
+		}
+	}
+	return r.uploadFiles(ctx, r.mirrors, objects)
+}
+
+// uploadFiles uploads the objects to all of the backends. The first failure
+// stops the uploads to that backend, but not to the others, and all failures
+// are reported together.
+func (r *Releaser) uploadFiles(ctx context.Context, backends []storage.Backend, objects []storageObject) error {
+	var failed errorList
+	for _, backend := range backends {
+		for _, o := range objects {
+			if err := backend.Upload(ctx, o.name, o.file, o.contentType); err != nil {
+				failed = append(failed, errors.Wrapf(err, "error uploading %s to %s", o.name, backend.BaseURL()))
+				break
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// verifyRemote makes sure that the remote points at the configured repository,
+// so that the index is not pushed to an unexpected place.
+func (r *Releaser) verifyRemote() error {
+	remoteURL, err := r.git.GetRemoteURL(r.config.Remote)
+	if err != nil {
+		return err
+	}
+	owner, repo, err := git.RepositoryFromURL(remoteURL)
+	if err != nil {
+		return errors.Wrapf(err, "error verifying remote %s", r.config.Remote)
+	}
+	if !strings.EqualFold(owner, r.config.Owner) || !strings.EqualFold(repo, r.config.GitRepo) {
+		return errors.Errorf("remote %s points at %s/%s instead of %s/%s, refusing to push the index to it",
+			r.config.Remote, owner, repo, r.config.Owner, r.config.GitRepo)
+	}
+	return nil
+}
+
+// pushIndexFile commits the index file to the pages branch and pushes it, or
+// creates a pull request for it, depending on the configuration. The given
+// chart packages are committed next to the index file.
+func (r *Releaser) pushIndexFile(message string, chartPackages []string) error {
+	return r.pushIndexFiles(message, []*indexUpdate{{releaser: r, pagesPackages: chartPackages}})
+}
+
+// pushIndexFiles commits the index files of the updates to the pages branch
+// in a single commit, together with their pages packages.
+func (r *Releaser) pushIndexFiles(message string, updates []*indexUpdate) error {
+	if !r.config.Push && !r.config.PR {
+		return nil
+	}
+
+	if err := r.verifyRemote(); err != nil {
+		return err
+	}
+
+	worktree, err := r.addWorktree(r.config.Remote + "/" + r.config.PagesBranch)
+	if err != nil {
+		return err
+	}
+	defer r.removeWorktree(worktree)
+
+	var files []string
+	for _, u := range updates {
+		copied, err := u.releaser.copyIndexFiles(worktree, u.pagesPackages)
+		if err != nil {
+			return err
+		}
+		files = append(files, copied...)
+	}
+	if err := r.git.Add(worktree, files...); err != nil {
+		return err
+	}
+	if err := r.git.Commit(worktree, message); err != nil {
+		return err
+	}
+
+	pushURL, err := r.git.GetPushURL(r.config.Remote, r.config.Token)
+	if err != nil {
+		return err
+	}
+
+	if r.config.Push {
+		r.logger.Printf("Pushing to branch %q", r.config.PagesBranch)
+		if err := r.git.Push(worktree, pushURL, "HEAD:refs/heads/"+r.config.PagesBranch); err != nil {
+			return err
+		}
+	} else if r.config.PR {
+		branch := fmt.Sprintf("chart-releaser-%s", randomString(16))
+
+		r.logger.Printf("Pushing to branch %q", branch)
+		if err := r.git.Push(worktree, pushURL, "HEAD:refs/heads/"+branch); err != nil {
+			return err
+		}
+		r.logger.Printf("Creating pull request against branch %q", r.config.PagesBranch)
+		prURL, err := r.github.CreatePullRequest(r.config.Owner, r.config.GitRepo, message, branch, r.config.PagesBranch)
+		if err != nil {
+			return err
+		}
+		r.logger.Printf("Pull request created: %s", prURL)
+	}
+
+	return nil
+}
+
+// copyIndexFiles copies the index file of the releaser, the files written next
+// to it and the given chart packages to the worktree of the pages branch. It
+// returns the copied files.
+func (r *Releaser) copyIndexFiles(worktree string, chartPackages []string) ([]string, error) {
+	pagesIndexPath := r.config.PagesIndexPath
+	if pagesIndexPath == "" {
+		pagesIndexPath = "index.yaml"
+	}
+	indexYamlPath := filepath.Join(worktree, pagesIndexPath)
+	if err := os.MkdirAll(filepath.Dir(indexYamlPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := copyFile(r.config.IndexPath, indexYamlPath); err != nil {
+		return nil, err
+	}
+	files := []string{indexYamlPath}
+	var indexFiles []string
+	if r.config.GzipIndex {
+		indexFiles = append(indexFiles, r.compressedIndexFile())
+	}
+	if r.config.WriteManifest {
+		checksumFile, manifestFile := r.manifestFiles()
+		indexFiles = append(indexFiles, checksumFile, manifestFile)
+	}
+	if r.config.SignIndex {
+		indexFiles = append(indexFiles, r.indexSignatureFile())
+	}
+	if r.config.GenerateHTML {
+		indexFiles = append(indexFiles, r.htmlFile())
+	}
+	for _, file := range indexFiles {
+		dst := filepath.Join(filepath.Dir(indexYamlPath), filepath.Base(file))
+		if err := copyFile(file, dst); err != nil {
+			return nil, err
+		}
+		files = append(files, dst)
+	}
+	for _, chartPackage := range chartPackages {
+		for _, file := range []string{chartPackage, chartPackage + ".prov"} {
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+			dst := filepath.Join(filepath.Dir(indexYamlPath), filepath.Base(file))
+			if err := copyFile(file, dst); err != nil {
+				return nil, err
+			}
+			files = append(files, dst)
+		}
+	}
+	return files, nil
+}
+
+// addReleasedPackages adds the chart packages to the index, pointing at the
+// assets of their GitHub releases.
+func (r *Releaser) addReleasedPackages(ctx context.Context, indexFile *repo.IndexFile, chartPackages []string, digests packageDigests) (bool, error) {
+	var err error
+	if r.config.SkipDeprecated {
+		// no releases are created for them
+		if chartPackages, err = r.dropDeprecated(chartPackages); err != nil {
+			return false, err
+		}
+	}
+
+	var update bool
+	for _, chartPackage := range chartPackages {
+		ch, err := loader.LoadFile(chartPackage)
+		if err != nil {
+			return false, err
+		}
+		tag, err := r.releaseTag(ch, chartPackage)
+		if err != nil {
+			return false, err
+		}
+
+		release, err := r.github.GetRelease(ctx, tag)
+		if err != nil {
+			return false, err
+		}
+		if release.Draft {
+			r.logger.Printf("Release %s is a draft, it is indexed once published", tag)
+			continue
+		}
+
+		if r.assetNameTemplate != nil {
+			added, err := r.addNamedAsset(indexFile, release, ch, chartPackage, digests)
+			if err != nil {
+				return false, err
+			}
+			update = update || added
+			continue
+		}
+
+		for _, asset := range release.Assets {
+			downloadUrl, _ := url.Parse(asset.URL)
+			name := filepath.Base(downloadUrl.Path)
+			// skip provenance files and other assets which are no chart packages
+			if filepath.Ext(name) != ".tgz" {
+				continue
+			}
+			baseName := strings.TrimSuffix(name, filepath.Ext(name))
+			packageName, packageVersion, err := r.splitPackageNameAndVersion(baseName)
+			if err != nil {
+				return false, errors.Wrapf(err, "invalid asset of release %s", tag)
+			}
+			r.logger.Event("found-asset", logging.Fields{"chart": packageName, "version": packageVersion, "tag": tag},
+				"Found %s-%s.tgz", packageName, packageVersion)
+			if _, err := indexFile.Get(packageName, packageVersion); err != nil {
+				if err := r.addToIndexFile(indexFile, chartPackage, downloadUrl.String(), digests); err != nil {
+					return false, err
+				}
+				update = true
+				break
+			}
+		}
+	}
+	return update, nil
+}
+
+// addNamedAsset adds the chart package to the index with the URL of the asset
+// of the release named by the asset name template, unless the chart version is
+// part of the index already. Releases without such an asset are skipped.
+func (r *Releaser) addNamedAsset(indexFile *repo.IndexFile, release *github.Release, ch *chart.Chart, chartPackage string, digests packageDigests) (bool, error) {
+	name, err := r.assetName(ch, chartPackage)
+	if err != nil {
+		return false, err
+	}
+	for _, asset := range release.Assets {
+		if asset.FileName() != name {
+			continue
+		}
+		r.logger.Event("found-asset", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "tag": release.Tag},
+			"Found %s", name)
+		if indexFile.Has(r.publishedName(ch.Metadata.Name), ch.Metadata.Version) {
+			return false, nil
+		}
+		if err := r.addToIndexFile(indexFile, chartPackage, asset.URL, digests); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// addStoredPackages uploads the chart packages that are not part of the index
+// yet to the storage backend and adds them to the index.
+func (r *Releaser) addStoredPackages(ctx context.Context, indexFile *repo.IndexFile, chartPackages []string, digests packageDigests) (bool, error) {
+	var update bool
+	for _, chartPackage := range chartPackages {
+		ch, err := loader.LoadFile(chartPackage)
+		if err != nil {
+			return false, err
+		}
+		if indexFile.Has(r.publishedName(ch.Metadata.Name), ch.Metadata.Version) {
+			continue
+		}
+
+		name := filepath.Base(chartPackage)
+		if r.config.DryRun {
+			r.printDryRun("upload", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "url": r.storage.BaseURL() + "/" + name},
+				"upload package=%s url=%s", chartPackage, r.storage.BaseURL()+"/"+name)
+		} else if err := r.storage.Upload(ctx, name, chartPackage, storage.ContentTypePackage); err != nil {
+			return false, err
+		}
+		provFile := fmt.Sprintf("%s.prov", chartPackage)
+		if _, err := os.Stat(provFile); err == nil && !r.config.DryRun {
+			if err := r.storage.Upload(ctx, name+".prov", provFile, storage.ContentTypeProvenance); err != nil {
+				return false, err
+			}
+		}
+
+		if err := r.addToIndexFile(indexFile, chartPackage, r.storage.BaseURL()+"/"+name, digests); err != nil {
+			return false, err
+		}
+		update = true
+	}
+	return update, nil
+}
+
+// dropRemoteEntries removes the entries of chart versions which are not in
+// the given chart packages from the index, so that the index only contains
+// the local chart packages. It reports whether any entry was removed.
+func (r *Releaser) dropRemoteEntries(indexFile *repo.IndexFile, chartPackages []string) (bool, error) {
+	local := make(map[string]bool, len(chartPackages))
+	for _, chartPackage := range chartPackages {
+		ch, err := loader.LoadFile(chartPackage)
+		if err != nil {
+			return false, err
+		}
+		local[r.publishedName(ch.Metadata.Name)+"-"+ch.Metadata.Version] = true
+	}
+
+	var dropped bool
+	for name, entries := range indexFile.Entries {
+		if !r.onlyChart(name) {
+			// left untouched when regenerating the entries of some charts
+			continue
+		}
+		var kept repo.ChartVersions
+		for _, entry := range entries {
+			if local[name+"-"+entry.Version] {
+				kept = append(kept, entry)
+				continue
+			}
+			// in dry-run mode, the removal is printed with the other changes
+			// of the index
+			if !r.config.DryRun {
+				r.logger.Event("remove-from-index", logging.Fields{"chart": name, "version": entry.Version},
+					"Removing %s-%s from index, it is not in the package path", name, entry.Version)
+			}
+			dropped = true
+		}
+		if len(kept) == 0 {
+			delete(indexFile.Entries, name)
+		} else {
+			indexFile.Entries[name] = kept
+		}
+	}
+	return dropped, nil
+}
+
+// indexContent returns the content of the index without the time it was
+// generated, so that indexes differing only in that time compare equal.
+func indexContent(indexFile *repo.IndexFile) ([]byte, error) {
+	content := *indexFile
+	content.Generated = time.Time{}
+	return json.Marshal(&content)
+}
+
+// indexVersions returns the set of chart versions in the index, keyed by
+// name and version.
+func indexVersions(indexFile *repo.IndexFile) map[string]bool {
+	versions := make(map[string]bool)
+	for name, entries := range indexFile.Entries {
+		for _, entry := range entries {
+			versions[name+"-"+entry.Version] = true
+		}
+	}
+	return versions
+}
+
+// addedVersions returns the chart versions of the index that are not part of
+// the published versions, in the order of the sorted index.
+func addedVersions(published map[string]bool, indexFile *repo.IndexFile) []*repo.ChartVersion {
+	var names []string
+	for name := range indexFile.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var added []*repo.ChartVersion
+	for _, name := range names {
+		for _, entry := range indexFile.Entries[name] {
+			if !published[name+"-"+entry.Version] {
+				added = append(added, entry)
+			}
+		}
+	}
+	return added
+}
+
+// commitMessageData is passed to the commit message template. Charts are the
+// chart versions added to the index, sorted by name.
+type commitMessageData struct {
+	Charts []*repo.ChartVersion
+}
+
+func computeCommitMessage(tmpl *template.Template, added []*repo.ChartVersion) (string, error) {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, commitMessageData{Charts: added}); err != nil {
+		return "", errors.Wrap(err, "error computing commit message")
+	}
+	return buffer.String(), nil
+}
+
+// printDryRun prints an action that would have been taken if dry-run mode
+// was not enabled.
+func (r *Releaser) printDryRun(action string, fields logging.Fields, format string, a ...interface{}) {
+	fields["dry_run"] = true
+	r.logger.Event(action, fields, "[dry-run] "+format, a...)
+}
+
+// pagesBaseURL returns the URL the chart packages committed next to the index
+// are served from without trailing slashes, e.g. https://org.github.io/repo
+// for a GitHub Pages project site or https://charts.example.com for a custom
+// domain.
+func (r *Releaser) pagesBaseURL() string {
+	baseURL := r.config.BaseURL
+	if baseURL == "" {
+		baseURL = r.config.ChartsRepo
+	}
+	return strings.TrimRight(baseURL, "/")
+}
+
+// addPagesPackages adds the chart packages that are not part of the index yet
+// to the index, pointing at the base URL, which defaults to the charts
+// repository. The packages are committed to the pages branch together with the
+// index. It returns the added packages.
+func (r *Releaser) addPagesPackages(indexFile *repo.IndexFile, chartPackages []string, digests packageDigests) ([]string, error) {
+	var added []string
+	for _, chartPackage := range chartPackages {
+		ch, err := loader.LoadFile(chartPackage)
+		if err != nil {
+			return nil, err
+		}
+		if indexFile.Has(r.publishedName(ch.Metadata.Name), ch.Metadata.Version) {
+			continue
+		}
+
+		url := r.pagesBaseURL() + "/" + filepath.Base(chartPackage)
+		if err := r.addToIndexFile(indexFile, chartPackage, url, digests); err != nil {
+			return nil, err
+		}
+		added = append(added, chartPackage)
+	}
+	return added, nil
+}
+
+// downloadIndexFile downloads the index.yaml of the charts repository to the
+// given path. It returns false if the charts repository has no index yet.
+func (r *Releaser) downloadIndexFile(ctx context.Context, path string) (bool, error) {
+	indexURL := r.remoteIndexURL()
+	header := http.Header{}
+	if r.config.Token != "" {
+		header.Set("Authorization", "Bearer "+r.config.Token)
+	}
+	resp, err := r.httpClient.Get(ctx, indexURL, header)
+	if err != nil {
+		return false, errors.Wrapf(err, "error downloading index %s", indexURL)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errors.Errorf("error downloading index %s: %s", indexURL, resp.Status)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// remoteIndexURL returns the URL of the existing index of the charts
+// repository, which defaults to the index.yaml in the charts repository.
+func (r *Releaser) remoteIndexURL() string {
+	if r.config.RemoteIndexURL != "" {
+		return r.config.RemoteIndexURL
+	}
+	if r.config.ChartsRepo == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/index.yaml", strings.TrimSuffix(r.config.ChartsRepo, "/"))
+}
+
+// defaultReleaseTemplate computes release tags and names if neither template
+// is configured.
+const defaultReleaseTemplate = "{{ .Name }}-{{ .Version }}"
+
+// parseReleaseTemplates parses the release tag and name templates. Each
+// defaults to the other one, so that configurations setting only the release
+// name template keep using it for the tag as well.
+func (r *Releaser) parseReleaseTemplates() (*template.Template, *template.Template, error) {
+	tagText := r.config.ReleaseTagTemplate
+	if tagText == "" {
+		tagText = r.config.ReleaseNameTemplate
+	}
+	if tagText == "" {
+		tagText = defaultReleaseTemplate
+	}
+	nameText := r.config.ReleaseNameTemplate
+	if nameText == "" {
+		nameText = tagText
+	}
+
+	tagTemplate, err := parseTemplate("release-tag", tagText, r.config.TemplateEnv)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing release tag template")
+	}
+	nameTemplate, err := parseTemplate("release-name", nameText, r.config.TemplateEnv)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing release name template")
+	}
+	return tagTemplate, nameTemplate, nil
+}
+
+// releaseTag computes the release tag of the chart package with the release
+// tag template of its chart, which may be overridden per chart, see ForChart.
+func (r *Releaser) releaseTag(ch *chart.Chart, chartPackage string) (string, error) {
+	chartReleaser := r
+	if _, ok := r.config.Charts[strings.ToLower(ch.Metadata.Name)]; ok {
+		opts, err := r.config.ForChart(ch.Metadata.Name)
+		if err != nil {
+			return "", err
+		}
+		overridden := *r
+		overridden.config = opts
+		chartReleaser = &overridden
+	}
+	tagTemplate, _, err := chartReleaser.parseReleaseTemplates()
+	if err != nil {
+		return "", err
+	}
+	return chartReleaser.computeReleaseName(tagTemplate, ch, chartPackage)
+}
+
+// parseTemplate parses a template with the Sprig functions available. Using
+// undefined fields or keys fails when the template is executed. If allowedEnv
+// is not empty, the env and expandenv functions only read the environment
+// variables listed in it.
+func parseTemplate(name string, text string, allowedEnv []string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs(allowedEnv)).Option("missingkey=error").Parse(text)
+}
+
+func templateFuncs(allowedEnv []string) template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	if len(allowedEnv) == 0 {
+		return funcs
+	}
+	allowed := make(map[string]bool, len(allowedEnv))
+	for _, key := range allowedEnv {
+		allowed[key] = true
+	}
+	env := func(key string) (string, error) {
+		if !allowed[key] {
+			return "", errors.Errorf("environment variable %s is not in template-env", key)
+		}
+		return os.Getenv(key), nil
+	}
+	funcs["env"] = env
+	funcs["expandenv"] = func(s string) (string, error) {
+		var err error
+		expanded := os.Expand(s, func(key string) string {
+			value, e := env(key)
+			if e != nil && err == nil {
+				err = e
+			}
+			return value
+		})
+		return expanded, err
+	}
+	return funcs
+}
+
+// renderBaseURL evaluates a base URL containing template actions, e.g. to read
+// the domain from an environment variable.
+func renderBaseURL(name string, baseURL string, allowedEnv []string) (string, error) {
+	if !strings.Contains(baseURL, "{{") {
+		return baseURL, nil
+	}
+	tmpl, err := parseTemplate(name, baseURL, allowedEnv)
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing %s", name)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", errors.Wrapf(err, "error rendering %s", name)
+	}
+	return b.String(), nil
+}
+
+// releaseNameData is passed to the release tag and name templates. In addition to the
+// chart metadata it provides the directory of the chart package relative to
+// the package path.
+type releaseNameData struct {
+	*chart.Metadata
+	Path string
+}
+
+func (r *Releaser) computeReleaseName(tmpl *template.Template, chart *chart.Chart, chartPackage string) (string, error) {
+	data := releaseNameData{
+		Metadata: r.publishedMetadata(chart.Metadata),
+		Path:     r.packageDir(chartPackage),
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+
+	releaseName := buffer.String()
+	return releaseName, nil
+}
+
+// publishedName returns the name a chart is released and indexed under, which
+// is its alias if one is configured and the chart name otherwise.
+func (r *Releaser) publishedName(name string) string {
+	if alias := r.config.ChartAliases[strings.ToLower(name)]; alias != "" {
+		return alias
+	}
+	return name
+}
+
+// publishedMetadata returns the chart metadata with the published name of the
+// chart. Aliased metadata is a copy, so that the chart itself keeps its name.
+func (r *Releaser) publishedMetadata(md *chart.Metadata) *chart.Metadata {
+	name := r.publishedName(md.Name)
+	if name == md.Name {
+		return md
+	}
+	published := *md
+	published.Name = name
+	return &published
+}
+
+// releaseNotesData is passed to the release notes template. In addition to the
+// chart metadata it provides the directory, download URL and digest of the
+// chart package.
+type releaseNotesData struct {
+	*chart.Metadata
+	Path   string
+	URL    string
+	Digest string
+}
+
+func (r *Releaser) computeReleaseNotes(tmpl *template.Template, chart *chart.Chart, tag string, chartPackage string) (string, error) {
+	digest, err := provenance.DigestFile(chartPackage)
+	if err != nil {
+		return "", err
+	}
+
+	assetName, err := r.assetName(chart, chartPackage)
+	if err != nil {
+		return "", err
+	}
+
+	data := releaseNotesData{
+		Metadata: r.publishedMetadata(chart.Metadata),
+		Path:     r.packageDir(chartPackage),
+		URL:      r.releaseAssetURL(tag, assetName),
+		Digest:   digest,
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// readReleaseNotesFile returns the contents of the release notes file of the
+// chart. It is looked up in the chart directory below the charts directory,
+// if one is configured, and then in the chart package. It reports false if
+// neither contains the file.
+func (r *Releaser) readReleaseNotesFile(ch *chart.Chart, chartPackage string) (string, bool, error) {
+	name := filepath.ToSlash(filepath.Clean(r.config.ReleaseNotesFile))
+	if r.config.ChartsDir != "" {
+		file := filepath.Join(r.config.ChartsDir, filepath.FromSlash(r.packageDir(chartPackage)), ch.Metadata.Name, filepath.FromSlash(name))
+		notes, err := ioutil.ReadFile(file)
+		if err == nil {
+			return string(notes), true, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", false, err
+		}
+	}
+	for _, file := range ch.Files {
+		if file.Name == name {
+			return string(file.Data), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// addExtraAssets adds the files in the chart directory below the charts
+// directory matching the extra asset globs, e.g. values.schema.json, to the
+// assets of the release. They are not added to the index.
+func (r *Releaser) addExtraAssets(release *github.Release, ch *chart.Chart, chartPackage string) error {
+	if len(r.config.ExtraAssetGlobs) == 0 {
+		return nil
+	}
+	chartDir, err := r.chartDir(ch, chartPackage)
+	if err != nil {
+		return err
+	}
+	if chartDir == "" {
+		r.logger.Event("extra-assets-missing", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version},
+			"No directory of chart %s found in %s, attaching no extra assets", ch.Metadata.Name, r.config.ChartsDir)
+		return nil
+	}
+	names := make(map[string]string, len(release.Assets))
+	for _, asset := range release.Assets {
+		names[asset.FileName()] = asset.Path
+	}
+	for _, pattern := range r.config.ExtraAssetGlobs {
+		matches, err := filepath.Glob(filepath.Join(chartDir, pattern))
+		if err != nil {
+			return errors.Wrapf(err, "invalid extra asset glob %q", pattern)
+		}
+		if len(matches) == 0 {
+			r.logger.Event("extra-assets-missing", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "glob": pattern},
+				"Extra asset glob %q matches no files in %s", pattern, chartDir)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+			name := filepath.Base(match)
+			if other, ok := names[name]; ok {
+				if other == match {
+					continue
+				}
+				return errors.Errorf("assets %s and %s of %s have the same name", other, match, ch.Metadata.Name)
+			}
+			names[name] = match
+			release.Assets = append(release.Assets, &github.Asset{Path: match})
+		}
+	}
+	return nil
+}
+
+// chartDir returns the directory below the charts directory the chart package
+// was packaged from, or an empty string if there is none. Charts are packaged
+// from the directories below the one matching the directory of the package
+// relative to the package path, see packageCharts, which are not necessarily
+// named after the chart.
+func (r *Releaser) chartDir(ch *chart.Chart, chartPackage string) (string, error) {
+	parent := filepath.Join(r.config.ChartsDir, filepath.FromSlash(r.packageDir(chartPackage)))
+	candidates := []string{filepath.Join(parent, ch.Metadata.Name)}
+	infos, err := ioutil.ReadDir(parent)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, info := range infos {
+		if info.IsDir() && info.Name() != ch.Metadata.Name {
+			candidates = append(candidates, filepath.Join(parent, info.Name()))
+		}
+	}
+	for _, dir := range candidates {
+		chartfile, err := chartutil.LoadChartfile(filepath.Join(dir, chartutil.ChartfileName))
+		if err == nil && chartfile.Name == ch.Metadata.Name {
+			return dir, nil
+		}
+	}
+	return "", nil
+}
+
+// assetName returns the name the chart package is uploaded as, which is its
+// file name unless an asset name template is set or the chart is aliased.
+func (r *Releaser) assetName(ch *chart.Chart, chartPackage string) (string, error) {
+	if r.assetNameTemplate == nil {
+		if name := r.publishedName(ch.Metadata.Name); name != ch.Metadata.Name {
+			return fmt.Sprintf("%s-%s.tgz", name, ch.Metadata.Version), nil
+		}
+		return filepath.Base(chartPackage), nil
+	}
+	name, err := r.computeReleaseName(r.assetNameTemplate, ch, chartPackage)
+	if err != nil {
+		return "", errors.Wrapf(err, "error computing the asset name of %s", chartPackage)
+	}
+	if filepath.Ext(name) != ".tgz" || strings.ContainsAny(name, "/\\") {
+		return "", errors.Errorf("asset name %q of %s must be a file name ending in .tgz", name, chartPackage)
+	}
+	return name, nil
+}
+
+// releaseAssetURL returns the URL under which GitHub serves the given asset of
+// a release once it has been uploaded.
+func (r *Releaser) releaseAssetURL(tag string, name string) string {
+	host := "github.com"
+	if baseURL, err := url.Parse(r.config.GitBaseURL); err == nil && baseURL.Host != "" && baseURL.Host != "api.github.com" {
+		host = baseURL.Host
+	}
+	return fmt.Sprintf("https://%s/%s/%s/releases/download/%s/%s", host, r.config.Owner, r.config.GitRepo, tag, name)
+}
+
+// packageDir returns the directory of the chart package relative to the
+// package path, using forward slashes. It is empty for packages directly in
+// the package path.
+func (r *Releaser) packageDir(chartPackage string) string {
+	for _, packagePath := range r.packagePaths() {
+		rel, err := filepath.Rel(packagePath, filepath.Dir(chartPackage))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if rel == "." {
+			return ""
+		}
+		return filepath.ToSlash(rel)
+	}
+	return ""
+}
+
+// packagePaths returns the directories with chart packages. The package path
+// may list several directories separated by commas.
+func (r *Releaser) packagePaths() []string {
+	var paths []string
+	for _, packagePath := range strings.Split(r.config.PackagePath, ",") {
+		if packagePath = strings.TrimSpace(packagePath); packagePath != "" {
+			paths = append(paths, packagePath)
+		}
+	}
+	return paths
+}
+
+// packageNameAndVersion matches the name of a chart package without extension,
+// i.e. the chart name followed by a hyphen and a SemVer version, which may
+// contain hyphens itself.
+var packageNameAndVersion = regexp.MustCompile(`^(.+)-(v?\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?)$`)
+
+// splitPackageNameAndVersion splits the name of a chart package without
+// extension into the chart name and version.
+func (r *Releaser) splitPackageNameAndVersion(pkg string) (string, string, error) {
+	// tolerate prefixes such as the directory of a chart in a monorepo
+	pkg = path.Base(pkg)
+	matches := packageNameAndVersion.FindStringSubmatch(pkg)
+	if matches == nil {
+		return "", "", errors.Errorf("%s is not of the form <name>-<version>", pkg)
+	}
+	return matches[1], matches[2], nil
+}
+
+// addToIndexFile adds the chart package to the index. The entry carries the
+// complete metadata of Chart.yaml, including annotations such as the
+// artifacthub.io/changes read by Artifact Hub. The digest of the package is
+// taken from digests if it has been computed already.
+func (r *Releaser) addToIndexFile(indexFile *repo.IndexFile, arch string, url string, digests packageDigests) error {
+
+	// extract chart metadata
+	r.logger.Printf("Extracting chart metadata from %s", arch)
+	c, err := loader.LoadFile(arch)
+	if err != nil {
+		return errors.Wrapf(err, "%s is not a helm chart package", arch)
+	}
+	// calculate hash
+	r.logger.Printf("Calculating Hash for %s", arch)
+	hash, err := digests.digest(arch)
+	if err != nil {
+		return err
+	}
+
+	// remove url name from url as helm's index library
+	// adds it in during .Add
+	// there should be a better way to handle this :(
+	s := strings.Split(url, "/")
+	name := s[len(s)-1]
+	s = s[:len(s)-1]
+
+	var templatedURL string
+	if r.urlTemplate != nil {
+		if templatedURL, err = r.computeURL(c, arch, url); err != nil {
+			return errors.Wrapf(err, "error computing the url of %s", arch)
+		}
+	}
+	// the packages are uploaded to the mirrors under their asset name, see
+	// mirrorPackages
+	var mirrorName string
+	if len(r.config.MirrorBaseURLs) > 0 {
+		if mirrorName, err = r.assetName(c, arch); err != nil {
+			return err
+		}
+	}
+
+	// Add to index under the published name, the digest is still the one of
+	// the chart package itself
+	r.progress(PhaseIndex, c.Metadata, false, nil)
+	md := r.publishedMetadata(c.Metadata)
+	err = indexFile.MustAdd(md, name, strings.Join(s, "/"), hash)
+	if err == nil {
+		versions := indexFile.Entries[md.Name]
+		entry := versions[len(versions)-1]
+		if templatedURL != "" {
+			entry.URLs = []string{templatedURL}
+		}
+		if r.config.GeneratedTimestamp != "" {
+			entry.Created, err = r.config.GeneratedTime()
+		}
+		// Helm falls back to the further URLs of an entry if the chart can
+		// not be downloaded from the first one.
+		for _, mirror := range r.config.MirrorBaseURLs {
+			entry.URLs = append(entry.URLs, strings.TrimSuffix(mirror, "/")+"/"+mirrorName)
+		}
+	}
+	r.progress(PhaseIndex, c.Metadata, true, err)
+	return err
+}
+
+// urlData is passed to the url template. In addition to the chart metadata it
+// provides the file name of the chart package and the URL it is downloaded
+// from otherwise.
+type urlData struct {
+	*chart.Metadata
+	Filename string
+	URL      string
+}
+
+func (r *Releaser) computeURL(chart *chart.Chart, chartPackage string, url string) (string, error) {
+	data := urlData{
+		Metadata: r.publishedMetadata(chart.Metadata),
+		Filename: filepath.Base(chartPackage),
+		URL:      url,
+	}
+
+	var buffer bytes.Buffer
+	if err := r.urlTemplate.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// Package packages all charts found in the charts directory into the package
+// path. Charts whose version has already been packaged are skipped.
+func (r *Releaser) Package() error {
+	chartDirs, err := r.findCharts(r.config.ChartsDir)
+	if err != nil {
+		return err
+	}
+	_, err = r.packageCharts(chartDirs)
+	return err
+}
+
+// ReleaseFromSource clones the source repository, packages the charts in
+// charts-dir of the clone, or in its charts directory if charts-dir is not
+// set, and releases them. The clone is removed afterwards.
+func (r *Releaser) ReleaseFromSource(ctx context.Context) error {
+	if r.config.Since != "" {
+		return errors.New("since is not supported with source-repo")
+	}
+
+	r.logger.Event("clone", logging.Fields{"repo": r.config.SourceRepo, "ref": r.config.SourceRef}, "Cloning %s", r.config.SourceRepo)
+	dir, err := r.git.Clone(r.config.SourceRepo, r.config.Token, r.config.SourceRef)
+	if err != nil {
+		return errors.Wrapf(err, "error cloning %s", r.config.SourceRepo)
+	}
+	defer os.RemoveAll(dir)
+
+	chartsDir := r.config.ChartsDir
+	if chartsDir == "" {
+		chartsDir = "charts"
+	}
+	source := *r
+	sourceConfig := *r.config
+	sourceConfig.ChartsDir = filepath.Join(dir, chartsDir)
+	source.config = &sourceConfig
+
+	if err := source.Package(); err != nil {
+		return err
+	}
+	return source.CreateReleases(ctx)
+}
+
+// ReleaseChanged packages and releases only the charts in the charts directory
+// with files changed since the configured git revision, e.g. the previous
+// tag. Unchanged charts are skipped.
+func (r *Releaser) ReleaseChanged(ctx context.Context) error {
+	if r.config.ChartsDir == "" || r.config.Since == "" {
+		return errors.New("charts-dir and since must be set to release changed charts only")
+	}
+
+	changedFiles, err := r.git.ChangedFiles("", r.config.Since)
+	if err != nil {
+		return errors.Wrapf(err, "error listing files changed since %s", r.config.Since)
+	}
+	chartDirs, err := r.findCharts(r.config.ChartsDir)
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	for _, dir := range chartDirs {
+		ok, err := containsAny(dir, changedFiles)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			r.logger.Event("skip-unchanged", logging.Fields{"path": dir, "since": r.config.Since},
+				"Chart in %s has not changed since %s, skipping", dir, r.config.Since)
+			continue
+		}
+		changed = append(changed, dir)
+	}
+	if len(changed) == 0 {
+		r.logger.Event("no-changes", logging.Fields{"since": r.config.Since}, "No charts changed since %s", r.config.Since)
+		return nil
+	}
+
+	chartPackages, err := r.packageCharts(changed)
+	if err != nil {
+		return err
+	}
+	var packages []string
+	for _, p := range chartPackages {
+		skip, err := r.skipChart(p)
+		if err != nil {
+			return err
+		}
+		if !skip {
+			packages = append(packages, p)
+		}
+	}
+	if r.config.DryRun {
+		// charts which would have been packaged cannot be released in dry-run mode
+		var existing []string
+		for _, p := range packages {
+			if _, err := os.Stat(p); err == nil {
+				existing = append(existing, p)
+			}
+		}
+		packages = existing
+	}
+	if len(packages) == 0 {
+		return nil
+	}
+	return r.createReleases(ctx, packages)
+}
+
+// containsAny reports whether any of the given files is below dir. Relative
+// paths are relative to the working directory.
+func containsAny(dir string, files []string) (bool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		file, err := filepath.Abs(filepath.FromSlash(file))
+		if err != nil {
+			return false, err
+		}
+		if strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// packageCharts packages the charts in the given directories and returns the
+// paths of their packages, including those which had been packaged already.
+func (r *Releaser) packageCharts(chartDirs []string) ([]string, error) {
+	// Charts are packaged into the same directory relative to the package path
+	// as their parent directory relative to the charts directory, so that
+	// the layout of a monorepo is available as .Path in templates.
+	var destinations, chartPackages []string
+	paths := make(map[string][]string)
+	metadata := make(map[string]*chart.Metadata)
+	for _, dir := range chartDirs {
+		ch, err := loader.LoadDir(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s is not a helm chart", dir)
+		}
+		metadata[dir] = ch.Metadata
+		parent, err := filepath.Rel(r.config.ChartsDir, filepath.Dir(dir))
+		if err != nil {
+			return nil, err
+		}
+		destination := filepath.Join(r.config.PackagePath, parent)
+		chartPackage := filepath.Join(destination, fmt.Sprintf("%s-%s.tgz", ch.Metadata.Name, ch.Metadata.Version))
+		chartPackages = append(chartPackages, chartPackage)
+		if _, err := os.Stat(chartPackage); err == nil {
+			r.logger.Event("skip-package", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version},
+				"Chart %s-%s has already been packaged, skipping", ch.Metadata.Name, ch.Metadata.Version)
+			continue
+		}
+		if _, ok := paths[destination]; !ok {
+			destinations = append(destinations, destination)
+		}
+		paths[destination] = append(paths[destination], dir)
+	}
+
+	for _, destination := range destinations {
+		if r.config.DryRun {
+			for _, dir := range paths[destination] {
+				r.printDryRun("package", logging.Fields{"path": dir}, "package chart=%s", dir)
+			}
+			continue
+		}
+		if err := os.MkdirAll(destination, 0755); err != nil {
+			return nil, err
+		}
+		options := *r.config
+		options.PackagePath = destination
+		for _, dir := range paths[destination] {
+			r.progress(PhasePackage, metadata[dir], false, nil)
+		}
+		chartPackager := packager.NewPackager(&options, paths[destination])
+		chartPackager.Logger = r.logger
+		err := chartPackager.CreatePackages()
+		for _, dir := range paths[destination] {
+			r.progress(PhasePackage, metadata[dir], true, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chartPackages, nil
+}
+
+// findCharts returns all directories below dir that contain a Chart.yaml.
+// Subcharts of a chart are not returned.
+func (r *Releaser) findCharts(dir string) ([]string, error) {
+	var chartDirs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, "Chart.yaml")); err == nil {
+			chartDirs = append(chartDirs, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return chartDirs, err
+}
+
+// CreateReleases finds and uploads Helm chart packages to GitHub. A package
+// path without chart packages is an error, unless AllowEmpty is set, e.g. for
+// pipelines which only package the charts that changed.
+func (r *Releaser) CreateReleases(ctx context.Context) error {
+	if r.config.PreflightAuth {
+		if checker, ok := r.github.(permissionChecker); ok {
+			if err := checker.CheckPermissions(ctx); err != nil {
+				return err
+			}
+			r.logger.Event("preflight-auth", logging.Fields{"owner": r.config.Owner, "repo": r.config.GitRepo},
+				"Token can create releases in %s/%s", r.config.Owner, r.config.GitRepo)
+		}
+	}
+
+	packages, err := r.getListOfPathPackages()
+	if err != nil {
+		return err
+	}
+
+	if len(packages) == 0 {
+		if r.config.AllowEmpty {
+			r.logger.Event("nothing-to-release", logging.Fields{"path": r.config.PackagePath},
+				"Nothing to release, no chart packages found at %s", r.config.PackagePath)
+			return nil
+		}
+		return errors.Errorf("no chart packages found at %s, set allow-empty if there may be nothing to release", r.config.PackagePath)
+	}
+	return r.createReleases(ctx, packages)
+}
+
+// chartGroup holds the packages released with the same options, i.e. those
+// of a chart with per-chart overrides or the remaining ones, together with the
+// releaser using these options and its templates.
+type chartGroup struct {
+	*Releaser
+	packages      []string
+	tagTemplate   *template.Template
+	nameTemplate  *template.Template
+	notesTemplate *template.Template
+}
+
+// chartGroups groups the packages by the options they are released with. The
+// packages of charts without per-chart overrides use the global options.
+func (r *Releaser) chartGroups(packages []string) ([]*chartGroup, error) {
+	var groups []*chartGroup
+	byChart := make(map[string]*chartGroup)
+	for _, p := range packages {
+		name, _, err := r.splitPackageNameAndVersion(strings.TrimSuffix(filepath.Base(p), ".tgz"))
+		if err != nil {
+			return nil, err
+		}
+		key := ""
+		if _, ok := r.config.Charts[strings.ToLower(name)]; ok {
+			key = strings.ToLower(name)
+		}
+		g, ok := byChart[key]
+		if !ok {
+			if g, err = r.newChartGroup(name, key != ""); err != nil {
+				if key != "" {
+					err = errors.Wrapf(err, "chart %s", name)
+				}
+				return nil, err
+			}
+			byChart[key] = g
+			groups = append(groups, g)
+		}
+		g.packages = append(g.packages, p)
+	}
+	return groups, nil
+}
+
+func (r *Releaser) newChartGroup(name string, overridden bool) (*chartGroup, error) {
+	g := &chartGroup{Releaser: r}
+	if overridden {
+		opts, err := r.config.ForChart(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateMakeReleaseLatest(opts.MakeReleaseLatest); err != nil {
+			return nil, err
+		}
+		chartReleaser := *r
+		chartReleaser.config = opts
+		g.Releaser = &chartReleaser
+	}
+
+	if len(g.config.ExtraAssetGlobs) > 0 && g.config.ChartsDir == "" {
+		return nil, errors.New("extra-asset-globs requires charts-dir, which the extra assets are looked up in")
+	}
+	var err error
+	if g.tagTemplate, g.nameTemplate, err = g.parseReleaseTemplates(); err != nil {
+		return nil, err
+	}
+	if g.config.ReleaseNotesTemplate != "" {
+		if g.notesTemplate, err = parseTemplate("release-notes", g.config.ReleaseNotesTemplate, g.config.TemplateEnv); err != nil {
+			return nil, errors.Wrap(err, "error parsing release notes template")
+		}
+	}
+	return g, nil
+}
+
+// createReleases creates a release for each of the given chart packages.
+func (r *Releaser) createReleases(ctx context.Context, packages []string) error {
+	if err := r.verifyPackageNames(packages); err != nil {
+		return err
+	}
+	var skipped int
+	if r.config.SkipDeprecated {
+		kept, err := r.dropDeprecated(packages)
+		if err != nil {
+			return err
+		}
+		skipped = len(packages) - len(kept)
+		packages = kept
+	}
+	var state *runState
+	if r.config.StateFile != "" {
+		var err error
+		if state, err = loadRunState(r.config.StateFile); err != nil {
+			return err
+		}
+		kept, err := r.dropCompleted(packages, state)
+		if err != nil {
+			return err
+		}
+		skipped += len(packages) - len(kept)
+		packages = kept
+	}
+	if r.config.ValidateValuesSchema {
+		if err := r.verifyValuesSchemas(packages); err != nil {
+			return err
+		}
+	}
+	if r.preReleaseHookTemplate != nil {
+		if err := r.runPreReleaseHooks(packages); err != nil {
+			return err
+		}
+	}
+	if err := r.verifyAssetSizes(packages); err != nil {
+		return err
+	}
+
+	// With skip-index, the index is maintained by another tool and not even
+	// downloaded, so that published versions are not verified against it.
+	var remoteIndex *repo.IndexFile
+	var err error
+	if !r.config.SkipIndex && (!r.config.AllowChangedVersions || r.config.GenerateReleaseNotes) {
+		if remoteIndex, err = r.loadRemoteIndex(ctx); err != nil {
+			return err
+		}
+	}
+
+	if !r.config.AllowChangedVersions {
+		if err := r.verifyPublishedDigests(packages, remoteIndex); err != nil {
+			return err
+		}
+	}
+
+	groups, err := r.chartGroups(packages)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		if g.config.VerifyProvenance {
+			if err := g.verifyProvenance(g.packages); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !r.config.DryRun {
+		for _, g := range groups {
+			if g.config.Sign {
+				if err := g.signPackages(g.packages); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if r.config.Cosign && !r.config.DryRun {
+		if err := r.cosignPackages(packages); err != nil {
+			return err
+		}
+	}
+
+	if r.config.GenerateSBOM && !r.config.DryRun {
+		if err := r.generateSBOMs(packages); err != nil {
+			return err
+		}
+	}
+
+	if r.config.OCIRegistry != "" && !r.config.DryRun {
+		if err := r.loginToRegistry(); err != nil {
+			return errors.Wrapf(err, "error logging in to OCI registry %s", r.config.OCIRegistry)
+		}
+	}
+
+	previousTags := make(map[string]string)
+	groupOf := make(map[string]*chartGroup, len(packages))
+	for _, g := range groups {
+		if r.config.GenerateReleaseNotes {
+			tags, err := g.previousTags(g.packages, remoteIndex)
+			if err != nil {
+				return err
+			}
+			for p, tag := range tags {
+				previousTags[p] = tag
+			}
+		}
+		for _, p := range g.packages {
+			groupOf[p] = g
+		}
+	}
+
+	concurrency := r.config.MaxConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// errs is indexed by package so that the reported errors keep the order of
+	// the packages, no matter in which order the workers finish.
+	errs := make([]error, len(packages))
+	released := make([]*releasedChart, len(packages))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, p := range packages {
+		sem <- struct{}{}
+		// no further releases are started once the context is cancelled
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int, p string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			g := groupOf[p]
+			released[i], errs[i] = g.createRelease(ctx, p, previousTags[p], g.tagTemplate, g.nameTemplate, g.notesTemplate)
+			if state != nil && errs[i] == nil && !r.config.DryRun {
+				r.recordCompleted(state, p)
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	r.printSummary(r.summarize(released, errs, skipped))
+
+	var failed errorList
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		failed = append(failed, errors.Wrap(err, "aborted creating releases"))
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+
+	if state != nil && !r.config.DryRun {
+		if err := state.remove(); err != nil {
+			r.logger.Printf("Warning: failed to remove state file %s: %s", r.config.StateFile, err)
+		}
+	}
+	if r.config.WebhookURL != "" {
+		r.notifyWebhook(ctx, released)
+	}
+	return nil
+}
+
+// dropCompleted returns the packages without the chart versions which the
+// state file records as released by a previous, interrupted run.
+func (r *Releaser) dropCompleted(packages []string, state *runState) ([]string, error) {
+	var kept []string
+	for _, p := range packages {
+		entry, err := r.stateEntry(p)
+		if err != nil {
+			return nil, err
+		}
+		if state.done(entry) {
+			r.logger.Event("skip-completed", logging.Fields{"chart": entry.Name, "version": entry.Version},
+				"Skipping %s-%s, it was released by a previous run recorded in %s", entry.Name, entry.Version, r.config.StateFile)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, nil
+}
+
+// recordCompleted records the released package in the state file. A failure
+// to write the state file only means that the release is repeated when
+// resuming, so it is logged instead of failing the release.
+func (r *Releaser) recordCompleted(state *runState, p string) {
+	entry, err := r.stateEntry(p)
+	if err == nil {
+		err = state.record(entry)
+	}
+	if err != nil {
+		r.logger.Printf("Warning: failed to record %s in state file %s: %s", p, r.config.StateFile, err)
+	}
+}
+
+// stateEntry returns the entry of the package in the state file.
+func (r *Releaser) stateEntry(p string) (stateEntry, error) {
+	name, version, err := r.splitPackageNameAndVersion(strings.TrimSuffix(filepath.Base(p), ".tgz"))
+	if err != nil {
+		return stateEntry{}, err
+	}
+	digest, err := provenance.DigestFile(p)
+	if err != nil {
+		return stateEntry{}, err
+	}
+	return stateEntry{Name: name, Version: version, Digest: digest}, nil
+}
+
+// releasedChart is a chart version released by CreateReleases, as reported to
+// the webhook.
+type releasedChart struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+
+	// bytes is the size of the uploaded assets. skipped is set if the release
+	// existed with all assets already.
+	bytes   int64
+	skipped bool
+}
+
+// webhookPayload is posted to the webhook after all releases succeeded. Text
+// summarizes the released charts for chat services such as Slack.
+type webhookPayload struct {
+	Text   string           `json:"text"`
+	Charts []*releasedChart `json:"charts"`
+}
+
+// notifyWebhook posts the released charts to the webhook. Failures are only
+// logged, as the releases have already been created.
+func (r *Releaser) notifyWebhook(ctx context.Context, released []*releasedChart) {
+	payload := webhookPayload{Charts: []*releasedChart{}}
+	var names []string
+	for _, chart := range released {
+		if chart == nil {
+			continue
+		}
+		payload.Charts = append(payload.Charts, chart)
+		names = append(names, chart.Name+" "+chart.Version)
+	}
+	if len(payload.Charts) == 0 {
+		return
+	}
+	payload.Text = "Released " + strings.Join(names, ", ")
+
+	if r.config.DryRun {
+		r.printDryRun("notify-webhook", logging.Fields{"charts": len(payload.Charts)}, "notify webhook charts=%d", len(payload.Charts))
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		r.logger.Event("notify-webhook-failed", logging.Fields{"error": err.Error()}, "Warning: failed to notify webhook: %s", err)
+		return
+	}
+	resp, err := r.httpClient.Post(ctx, r.config.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		r.logger.Event("notify-webhook-failed", logging.Fields{"error": err.Error()}, "Warning: failed to notify webhook: %s", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		r.logger.Event("notify-webhook-failed", logging.Fields{"status": resp.StatusCode}, "Warning: failed to notify webhook: %s", resp.Status)
+		return
+	}
+	r.logger.Event("notify-webhook", logging.Fields{"charts": len(payload.Charts)}, "Notified webhook about %d released charts", len(payload.Charts))
+}
+
+// Validate checks the chart packages in the package path without contacting
+// GitHub: every package must contain a valid chart whose version is valid
+// SemVer and matches its file name, and no chart version may be packaged
+// twice. All problems are reported together in a single error.
+func (r *Releaser) Validate() error {
+	packages, err := r.getListOfPathPackages()
+	if err != nil {
+		return err
+	}
+
+	if len(packages) == 0 {
+		return errors.Errorf("No charts found at %s.\n", r.config.PackagePath)
+	}
+
+	var problems errorList
+	seen := make(map[string]string)
+	for _, p := range packages {
+		ch, err := loader.LoadFile(p)
+		if err != nil {
+			problems = append(problems, errors.Wrapf(err, "%s is not a helm chart package", p))
+			continue
+		}
+		if err := ch.Validate(); err != nil {
+			problems = append(problems, errors.Wrapf(err, "%s contains an invalid chart", p))
+			continue
+		}
+		if _, err := semver.StrictNewVersion(ch.Metadata.Version); err != nil {
+			problems = append(problems, errors.Wrapf(err, "%s has invalid SemVer version %s", p, ch.Metadata.Version))
+		}
+		if ch.Metadata.Name+"-"+ch.Metadata.Version != strings.TrimSuffix(filepath.Base(p), ".tgz") {
+			problems = append(problems, errors.Errorf("%s contains chart %s version %s, which does not match its file name",
+				p, ch.Metadata.Name, ch.Metadata.Version))
+		}
+		key := ch.Metadata.Name + " " + ch.Metadata.Version
+		if other, ok := seen[key]; ok {
+			problems = append(problems, errors.Errorf("%s and %s both contain chart %s version %s",
+				other, p, ch.Metadata.Name, ch.Metadata.Version))
+			continue
+		}
+		seen[key] = p
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// verifyPackageNames makes sure that the file names of the packages match the
+// name and version of the charts they contain.
+func (r *Releaser) verifyPackageNames(packages []string) error {
+	var mismatched errorList
+	for _, p := range packages {
+		name, version, err := r.splitPackageNameAndVersion(strings.TrimSuffix(filepath.Base(p), ".tgz"))
+		if err != nil {
+			mismatched = append(mismatched, errors.Wrapf(err, "invalid package file name %s", p))
+			continue
+		}
+		ch, err := loader.LoadFile(p)
+		if err != nil {
+			mismatched = append(mismatched, errors.Wrapf(err, "%s is not a helm chart package", p))
+			continue
+		}
+		if ch.Metadata.Name != name || ch.Metadata.Version != version {
+			mismatched = append(mismatched, errors.Errorf("%s contains chart %s version %s, which does not match its file name",
+				p, ch.Metadata.Name, ch.Metadata.Version))
+		}
+	}
+	if len(mismatched) > 0 {
+		return mismatched
+	}
+	return nil
+}
+
+// verifyValuesSchemas makes sure that the default values of the charts, and of
+// their dependencies, are valid according to their values.schema.json.
+// Charts without a schema are not checked.
+func (r *Releaser) verifyValuesSchemas(packages []string) error {
+	var invalid errorList
+	for _, p := range packages {
+		ch, err := loader.LoadFile(p)
+		if err != nil {
+			return err
+		}
+		values, err := chartutil.CoalesceValues(ch, nil)
+		if err != nil {
+			return errors.Wrapf(err, "error reading the default values of %s", p)
+		}
+		if err := chartutil.ValidateAgainstSchema(ch, values); err != nil {
+			invalid = append(invalid, errors.Errorf("the default values of %s violate the values schema:\n%s",
+				p, strings.TrimSpace(err.Error())))
+		}
+	}
+	if len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// verifyAssetSizes makes sure that none of the files is larger than
+// max-asset-size, so that oversized assets are rejected before uploading
+// them. All oversized files are reported together.
+func (r *Releaser) verifyAssetSizes(files []string) error {
+	if r.config.MaxAssetSize <= 0 {
+		return nil
+	}
+	var oversized errorList
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if info.Size() > r.config.MaxAssetSize {
+			oversized = append(oversized, errors.Errorf("%s is %d bytes, larger than the max-asset-size of %d bytes", file, info.Size(), r.config.MaxAssetSize))
+		}
+	}
+	if len(oversized) > 0 {
+		return oversized
+	}
+	return nil
+}
+
+// verifyProvenance verifies the provenance files shipped with the packages
+// against the keyring, so that no package is released whose signature or
+// digest does not match. Packages without a provenance file are rejected as
+// well, unless they are signed before releasing them.
+func (r *Releaser) verifyProvenance(packages []string) error {
+	signatory, err := provenance.NewFromKeyring(r.config.KeyRing, "")
+	if err != nil {
+		return errors.Wrap(err, "error loading keyring for verifying provenance files")
+	}
+
+	var unverified errorList
+	for _, p := range packages {
+		provFile := p + ".prov"
+		if _, err := os.Stat(provFile); os.IsNotExist(err) {
+			if !r.config.Sign {
+				unverified = append(unverified, errors.Errorf("%s has no provenance file", p))
+			}
+			continue
+		}
+		verification, err := signatory.Verify(p, provFile)
+		if err != nil {
+			unverified = append(unverified, errors.Wrapf(err, "error verifying provenance of %s", p))
+			continue
+		}
+		r.logger.Event("verify-provenance", logging.Fields{"package": p, "signer": signerName(verification)},
+			"Verified provenance of %s signed by %s", p, signerName(verification))
+	}
+	if len(unverified) > 0 {
+		return unverified
+	}
+	return nil
+}
+
+// signerName returns the names of the identities of the key that signed a
+// verified package.
+func signerName(verification *provenance.Verification) string {
+	if verification.SignedBy == nil {
+		return ""
+	}
+	names := make([]string, 0, len(verification.SignedBy.Identities))
+	for name := range verification.SignedBy.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// verifyPublishedDigests makes sure that none of the packages changes a chart
+// version that has already been published to the index of the charts repository.
+func (r *Releaser) verifyPublishedDigests(packages []string, indexFile *repo.IndexFile) error {
+	if indexFile == nil {
+		return nil
+	}
+
+	var changed errorList
+	for _, p := range packages {
+		ch, err := loader.LoadFile(p)
+		if err != nil {
+			return err
+		}
+		published, err := indexFile.Get(r.publishedName(ch.Metadata.Name), ch.Metadata.Version)
+		if err != nil {
+			continue
+		}
+		digest, err := provenance.DigestFile(p)
+		if err != nil {
+			return err
+		}
+		if published.Digest != digest {
+			changed = append(changed, errors.Errorf("%s changes the already published version %s of chart %s (digest %s, published %s)",
+				p, ch.Metadata.Version, ch.Metadata.Name, digest, published.Digest))
+		}
+	}
+	if len(changed) > 0 {
+		return changed
+	}
+	return nil
+}
+
+// loadRemoteIndex downloads the index of the charts repository. It returns
+// nil if there is no remote index.
+func (r *Releaser) loadRemoteIndex(ctx context.Context) (*repo.IndexFile, error) {
+	if r.remoteIndexURL() == "" {
+		return nil, nil
+	}
+
+	dir, err := ioutil.TempDir("", "chart-releaser-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	indexPath := filepath.Join(dir, "index.yaml")
+	exists, err := r.downloadIndexFile(ctx, indexPath)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return repo.LoadIndexFile(indexPath)
+}
+
+// previousTags returns the tags of the releases preceding the chart packages,
+// keyed by package. The preceding release is the one of the previous stable
+// version of the same chart in the index, see previousStableVersion.
+func (r *Releaser) previousTags(packages []string, indexFile *repo.IndexFile) (map[string]string, error) {
+	tags := make(map[string]string)
+	if indexFile == nil {
+		return tags, nil
+	}
+	for _, p := range packages {
+		ch, err := loader.LoadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		version, err := semver.NewVersion(ch.Metadata.Version)
+		if err != nil {
+			continue
+		}
+
+		previous := previousStableVersion(indexFile.Entries[r.publishedName(ch.Metadata.Name)], version)
+		if previous == nil {
+			continue
+		}
+		tag, err := r.releaseTag(&chart.Chart{Metadata: previous.Metadata}, p)
+		if err != nil {
+			return nil, err
+		}
+		tags[p] = tag
+	}
+	return tags, nil
+}
+
+// previousStableVersion returns the entry of the highest stable version below
+// the given version in SemVer order, or nil if there is none. Prereleases are
+// skipped, so that the release notes of 1.1.0, and of 1.1.0-rc.2, cover the
+// changes since 1.0.0 rather than since 1.1.0-rc.1. Build metadata is ignored,
+// i.e. 1.0.0+build.2 does not precede 1.0.0+build.1. Entries whose version is
+// no valid SemVer are skipped.
+func previousStableVersion(entries repo.ChartVersions, version *semver.Version) *repo.ChartVersion {
+	var previous *repo.ChartVersion
+	var previousVersion *semver.Version
+	for _, entry := range entries {
+		v, err := semver.NewVersion(entry.Version)
+		if err != nil || v.Prerelease() != "" || !v.LessThan(version) {
+			continue
+		}
+		if previousVersion == nil || v.GreaterThan(previousVersion) {
+			previous, previousVersion = entry, v
+		}
+	}
+	return previous
+}
+
+// signPackages creates a provenance file for every chart package that does
+// not have one yet.
+func (r *Releaser) signPackages(packages []string) error {
+	signer, err := r.loadSigner()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range packages {
+		provFile := fmt.Sprintf("%s.prov", p)
+		if _, err := os.Stat(provFile); err == nil {
+			continue
+		}
+		r.logger.Event("sign", logging.Fields{"package": p}, "Signing %s", p)
+		sig, err := signer.ClearSign(p)
+		if err != nil {
+			return errors.Wrapf(err, "error signing %s", p)
+		}
+		if err := ioutil.WriteFile(provFile, []byte(sig), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cosignPackages creates a sigstore signature and bundle for every chart
+// package that does not have a signature yet. They are released next to the
+// package, independently of a provenance file.
+func (r *Releaser) cosignPackages(packages []string) error {
+	for _, p := range packages {
+		sigFile := fmt.Sprintf("%s.sig", p)
+		if _, err := os.Stat(sigFile); err == nil {
+			continue
+		}
+		r.logger.Event("cosign", logging.Fields{"package": p}, "Signing %s with sigstore", p)
+		if err := r.cosigner.SignBlob(p, sigFile, fmt.Sprintf("%s.bundle", p)); err != nil {
+			return errors.Wrapf(err, "error signing %s with sigstore", p)
+		}
+	}
+	return nil
+}
+
+// generateSBOMs writes an SBOM for every chart package, which is released next
+// to the package. Existing SBOMs are replaced, as they may have been generated
+// for an earlier build of the package.
+func (r *Releaser) generateSBOMs(packages []string) error {
+	for _, p := range packages {
+		sbomFile := p + sbom.Extension
+		r.logger.Event("generate-sbom", logging.Fields{"package": p}, "Generating SBOM for %s", p)
+		if err := r.sbom.Generate(p, sbomFile); err != nil {
+			return errors.Wrapf(err, "error generating SBOM for %s", p)
+		}
+	}
+	return nil
+}
+
+// signIndexFile writes an ASCII armored detached signature of the index file
+// next to it, which can be verified with e.g. gpg --verify.
+func (r *Releaser) signIndexFile() error {
+	signer, err := r.loadSigner()
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(r.config.IndexPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r.logger.Event("sign-index", logging.Fields{"index": r.config.IndexPath}, "Signing %s", r.config.IndexPath)
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, signer.Entity, in, nil); err != nil {
+		return errors.Wrapf(err, "error signing %s", r.config.IndexPath)
+	}
+	return ioutil.WriteFile(r.indexSignatureFile(), sig.Bytes(), 0644)
+}
+
+// indexSignatureFile returns the path of the detached signature of the index.
+func (r *Releaser) indexSignatureFile() string {
+	return r.config.IndexPath + ".asc"
+}
+
+// loadSigner loads the configured signing key from the keyring and decrypts
+// it.
+func (r *Releaser) loadSigner() (*provenance.Signatory, error) {
+	signer, err := provenance.NewFromKeyring(r.config.KeyRing, r.config.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading signing key")
+	}
+	if err := signer.DecryptKey(r.readPassphrase); err != nil {
+		return nil, errors.Wrap(err, "error decrypting signing key")
+	}
+	return signer, nil
+}
+
+// readPassphrase implements provenance.PassphraseFetcher. It reads the first
+// line of the configured passphrase file, or of stdin if the file is "-".
+func (r *Releaser) readPassphrase(name string) ([]byte, error) {
+	if r.config.PassphraseFile == "" {
+		return nil, errors.Errorf("key %q is encrypted, but no passphrase file is set", name)
+	}
+
+	in := os.Stdin
+	if r.config.PassphraseFile != "-" {
+		f, err := os.Open(r.config.PassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	passphrase, _, err := bufio.NewReader(in).ReadLine()
+	return passphrase, err
+}
+
+func (r *Releaser) createRelease(ctx context.Context, p string, previousTag string, tagTemplate *template.Template, nameTemplate *template.Template, notesTemplate *template.Template) (_ *releasedChart, err error) {
+	ch, err := loader.LoadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	r.progress(PhaseRelease, ch.Metadata, false, nil)
+	defer func() {
+		r.progress(PhaseRelease, ch.Metadata, true, err)
+	}()
+	tag, err := r.computeReleaseName(tagTemplate, ch, p)
+	if err != nil {
+		return nil, err
+	}
+	releaseName, err := r.computeReleaseName(nameTemplate, ch, p)
+	if err != nil {
+		return nil, err
+	}
+	description := ch.Metadata.Description
+	if notesTemplate != nil {
+		description, err = r.computeReleaseNotes(notesTemplate, ch, tag, p)
+		if err != nil {
+			return nil, err
+		}
+	} else if r.config.ReleaseNotesFile != "" {
+		notes, ok, err := r.readReleaseNotesFile(ch, p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			description = notes
+		}
+	}
+	assetName, err := r.assetName(ch, p)
+	if err != nil {
+		return nil, err
+	}
+	release := &github.Release{
+		Name:        releaseName,
+		Tag:         tag,
+		Description: description,
+		Assets: []*github.Asset{
+			{Path: p, Name: assetName},
+		},
+		Commit:     r.config.Commit,
+		Prerelease: r.config.MarkPrerelease || isPrerelease(ch.Metadata.Version),
+		Draft:      r.config.Draft,
+		MakeLatest: r.config.MakeReleaseLatest,
+
+		GenerateReleaseNotes: r.config.GenerateReleaseNotes,
+		PreviousTag:          previousTag,
+		DiscussionCategory:   r.config.DiscussionCategory,
+	}
+	for _, ext := range []string{".prov", ".sig", ".bundle", sbom.Extension} {
+		if ((ext == ".sig" || ext == ".bundle") && !r.config.Cosign) || (ext == sbom.Extension && !r.config.GenerateSBOM) {
+			// signatures and SBOMs left over from earlier runs are not
+			// published
+			continue
+		}
+		if _, err := os.Stat(p + ext); err == nil {
+			release.Assets = append(release.Assets, &github.Asset{Path: p + ext, Name: assetName + ext})
+		}
+	}
+	if err := r.addExtraAssets(release, ch, p); err != nil {
+		return nil, err
+	}
+	assets := make([]string, 0, len(release.Assets))
+	for _, asset := range release.Assets {
+		assets = append(assets, asset.Path)
+	}
+	if err := r.verifyAssetSizes(assets); err != nil {
+		return nil, err
+	}
+	released := &releasedChart{
+		Name:    r.publishedName(ch.Metadata.Name),
+		Version: ch.Metadata.Version,
+		URL:     r.releaseAssetURL(tag, assetName),
+	}
+	if r.config.Force {
+		if err := r.deleteExistingRelease(ctx, tag); err != nil {
+			return nil, err
+		}
+	}
+	if r.config.DryRun {
+		r.printDryRunRelease(ch, release)
+		return released, nil
+	}
+	if r.config.SkipExisting || r.config.UseExistingRelease {
+		existingRelease, err := r.existingRelease(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		if existingRelease != nil {
+			uploaded, err := r.completeRelease(ctx, existingRelease, release, p)
+			if err != nil {
+				return nil, err
+			}
+			released.bytes = assetBytes(uploaded)
+			released.skipped = len(uploaded) == 0
+			return released, r.pushToRegistry(p, ch)
+		}
+	}
+	if release.Commit != "" {
+		if err := r.reuseExistingTag(ctx, release); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	if err := r.github.CreateRelease(ctx, release); err != nil {
+		return nil, errors.Wrapf(err, "error creating GitHub release %s", tag)
+	}
+	r.logger.Event("create-release", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "tag": tag, "duration_ms": logging.DurationMillis(start)},
+		"Created release %s", tag)
+	released.bytes = assetBytes(release.Assets)
+
+	return released, r.pushToRegistry(p, ch)
+}
+
+// reuseExistingTag looks up the tag of the release. If it exists and points at
+// the commit the release is created for, the release reuses it instead of
+// asking GitHub to tag the commit. A tag pointing at a different commit is a
+// conflict.
+func (r *Releaser) reuseExistingTag(ctx context.Context, release *github.Release) error {
+	tagSHA, err := r.github.GetCommitSHA(ctx, "tags/"+release.Tag)
+	if err != nil {
+		return errors.Wrapf(err, "error looking up tag %s", release.Tag)
+	}
+	if tagSHA == "" {
+		return nil
+	}
+	commitSHA := release.Commit
+	if !strings.EqualFold(tagSHA, commitSHA) {
+		if commitSHA, err = r.github.GetCommitSHA(ctx, release.Commit); err != nil {
+			return errors.Wrapf(err, "error looking up commit %s", release.Commit)
+		}
+	}
+	if commitSHA == "" || !strings.EqualFold(tagSHA, commitSHA) {
+		return errors.Errorf("tag %s already exists and points at commit %s instead of %s", release.Tag, tagSHA, release.Commit)
+	}
+	r.logger.Event("reuse-tag", logging.Fields{"tag": release.Tag, "commit": tagSHA}, "Reusing existing tag %s at commit %s", release.Tag, tagSHA)
+	release.Commit = ""
+	return nil
+}
+
+// existingRelease returns the release of the given tag, or nil if there is
+// none. Errors other than the release not being found are returned.
+func (r *Releaser) existingRelease(ctx context.Context, tag string) (*github.Release, error) {
+	release, err := r.github.GetRelease(ctx, tag)
+	if errors.Is(err, github.ErrReleaseNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error looking up release %s", tag)
+	}
+	return release, nil
+}
+
+// deleteExistingRelease deletes the release of the given tag, including its
+// assets, and the tag itself if the release exists, so that it is created
+// anew from the current chart package and commit.
+func (r *Releaser) deleteExistingRelease(ctx context.Context, tag string) error {
+	existing, err := r.existingRelease(ctx, tag)
+	if existing == nil || err != nil {
+		return err
+	}
+	if r.config.DryRun {
+		r.printDryRun("delete-release", logging.Fields{"tag": tag}, "delete release tag=%s", tag)
+		return nil
+	}
+	r.logger.Event("delete-release", logging.Fields{"tag": tag}, "Force: deleting existing release %s and its tag", tag)
+	if err := r.github.DeleteRelease(ctx, existing); err != nil {
+		return errors.Wrapf(err, "error deleting release %s", tag)
+	}
+	if err := r.github.DeleteTag(ctx, tag); err != nil {
+		return errors.Wrapf(err, "error deleting tag %s", tag)
+	}
+	return nil
+}
+
+// isPrerelease reports whether version is a SemVer version with a prerelease
+// component, e.g. 1.0.0-rc.1.
+func isPrerelease(version string) bool {
+	v, err := semver.NewVersion(version)
+	return err == nil && v.Prerelease() != ""
+}
+
+// printDryRunRelease prints the release that would have been created.
+func (r *Releaser) printDryRunRelease(ch *chart.Chart, release *github.Release) {
+	var assets []string
+	for _, asset := range release.Assets {
+		assets = append(assets, asset.FileName())
+	}
+	r.printDryRun("create-release", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "name": release.Name, "tag": release.Tag, "commit": release.Commit, "prerelease": release.Prerelease, "draft": release.Draft, "assets": assets},
+		"create release name=%s tag=%s commit=%s prerelease=%t draft=%t assets=%s", release.Name, release.Tag, release.Commit, release.Prerelease, release.Draft, strings.Join(assets, ","))
+	if r.config.OCIRegistry != "" {
+		r.printDryRun("push", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "registry": r.config.OCIRegistry},
+			"push package=%s registry=%s", release.Assets[0].Path, r.config.OCIRegistry)
+	}
+}
+
+// completeRelease uploads the assets of release that are missing from the
+// already existing release, leaving its name and body untouched. It fails if
+// the existing chart package differs from the local one, or if any of the
+// assets exists already unless skip-existing is set. It returns the uploaded
+// assets.
+func (r *Releaser) completeRelease(ctx context.Context, existing *github.Release, release *github.Release, chartPackage string) ([]*github.Asset, error) {
+	existingAssets := make(map[string]*github.Asset, len(existing.Assets))
+	for _, asset := range existing.Assets {
+		existingAssets[asset.FileName()] = asset
+	}
+
+	var missing []*github.Asset
+	for _, asset := range release.Assets {
+		existingAsset, ok := existingAssets[asset.FileName()]
+		if !ok {
+			missing = append(missing, asset)
+			continue
+		}
+		if !r.config.SkipExisting {
+			return nil, errors.Errorf("release %s already has an asset %s", release.Tag, asset.FileName())
+		}
+		if asset.Path == chartPackage {
+			if err := r.verifyAssetDigest(ctx, existingAsset, chartPackage); err != nil {
+				return nil, errors.Wrapf(err, "release %s already exists", release.Tag)
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		r.logger.Event("skip-release", logging.Fields{"tag": release.Tag}, "Release %s already exists with all assets, skipping", release.Tag)
+		return nil, nil
+	}
+
+	for _, asset := range missing {
+		r.logger.Event("upload-asset", logging.Fields{"tag": release.Tag, "asset": asset.FileName()},
+			"Release %s already exists, uploading missing asset %s", release.Tag, asset.FileName())
+	}
+	if err := r.github.UploadAssets(ctx, existing, missing); err != nil {
+		return nil, errors.Wrapf(err, "error uploading assets to GitHub release %s", release.Tag)
+	}
+	return missing, nil
+}
+
+// verifyAssetDigest downloads the given release asset and compares its digest
+// with the one of the local file.
+func (r *Releaser) verifyAssetDigest(ctx context.Context, asset *github.Asset, file string) error {
+	expected, err := provenance.DigestFile(file)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.httpClient.Get(ctx, asset.URL, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to download %s: %s", asset.URL, resp.Status)
+	}
+
+	actual, err := provenance.Digest(resp.Body)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return errors.Errorf("digest of %s (%s) does not match the digest of %s (%s)", asset.URL, actual, file, expected)
+	}
+	return nil
+}
+
+// loginToRegistry logs in to ghcr.io with the GitHub token. Other registries
+// are expected to be configured in the Docker config already.
+func (r *Releaser) loginToRegistry() error {
+	host, err := registry.Host(r.config.OCIRegistry)
+	if err != nil {
+		return err
+	}
+	if host != "ghcr.io" || r.config.Token == "" {
+		return nil
+	}
+	return r.registry.Login(r.config.OCIRegistry, r.config.Owner, r.config.Token)
+}
+
+func (r *Releaser) pushToRegistry(p string, ch *chart.Chart) error {
+	if r.config.OCIRegistry == "" {
+		return nil
+	}
+	exists, err := r.registry.Exists(r.config.OCIRegistry, ch.Metadata.Name, ch.Metadata.Version)
+	if err != nil {
+		return err
+	}
+	if exists {
+		r.logger.Event("skip-push", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version},
+			"Chart %s-%s already exists in %s, skipping push", ch.Metadata.Name, ch.Metadata.Version, r.config.OCIRegistry)
+		return nil
+	}
+	start := time.Now()
+	if err := r.registry.Push(p, r.config.OCIRegistry); err != nil {
+		return errors.Wrapf(err, "error pushing %s to OCI registry %s", p, r.config.OCIRegistry)
+	}
+	r.logger.Event("push", logging.Fields{"chart": ch.Metadata.Name, "version": ch.Metadata.Version, "duration_ms": logging.DurationMillis(start)},
+		"Pushed %s to %s", p, r.config.OCIRegistry)
+	return nil
+}
+
+// prunedRelease is a release of a chart version which is not retained by the
+// retention policy.
+type prunedRelease struct {
+	release *github.Release
+	chart   string
+	version string
+}
+
+// Reconcile rebuilds the index entirely from the chart packages attached to the
+// releases of the repository, e.g. to recover from an index which drifted from
+// what is actually published. Entries of chart versions without a release are
+// dropped.
+func (r *Releaser) Reconcile(ctx context.Context) error {
+	releases, err := r.github.ListReleases(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error listing releases")
+	}
+
+	dir, err := ioutil.TempDir("", "chart-releaser-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	indexFile := repo.NewIndexFile()
+	for _, release := range releases {
+		if release.Draft {
+			r.logger.Printf("Skipping draft release %s", release.Tag)
+			continue
+		}
+		for _, asset := range release.Assets {
+			if filepath.Ext(asset.Path) != ".tgz" {
+				continue
+			}
+			expected, err := r.expectedDigest(ctx, release, asset)
+			if err != nil {
+				return errors.Wrapf(err, "error looking up the digest of %s of release %s", asset.Path, release.Tag)
+			}
+			if expected == "" {
+				r.logger.Printf("Release %s does not record the digest of %s, it is not verified", release.Tag, asset.Path)
+			}
+			file := filepath.Join(dir, filepath.Base(asset.Path))
+			if err := r.downloadVerified(ctx, asset.URL, file, expected); err != nil {
+				return errors.Wrapf(err, "error downloading chart package of release %s", release.Tag)
+			}
+			ch, err := loader.LoadFile(file)
+			if err != nil {
+				return errors.Wrapf(err, "%s of release %s is not a helm chart package", asset.Path, release.Tag)
+			}
+			if indexFile.Has(r.publishedName(ch.Metadata.Name), ch.Metadata.Version) {
+				r.logger.Printf("Skipping %s of release %s, chart %s version %s is part of another release", asset.Path, release.Tag, ch.Metadata.Name, ch.Metadata.Version)
+				continue
+			}
+			if err := r.addToIndexFile(indexFile, file, asset.URL, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	indexFile.SortEntries()
+	if indexFile.Generated, err = r.config.GeneratedTime(); err != nil {
+		return err
+	}
+	if r.config.DryRun {
+		return r.printReconcileDiff(ctx, indexFile)
+	}
+
+	r.logger.Printf("Writing index %s rebuilt from %d releases", r.config.IndexPath, len(releases))
+	if err := r.writeIndexFile(ctx, indexFile); err != nil {
+		return err
+	}
+	return r.pushIndexFile("Rebuild index.yaml from the published releases", nil)
+}
+
+// printReconcileDiff prints the entries the rebuilt index adds to, changes in
+// and removes from the published index of the charts repository, if there is
+// one.
+func (r *Releaser) printReconcileDiff(ctx context.Context, indexFile *repo.IndexFile) error {
+	existing, err := r.loadRemoteIndex(ctx)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		existing = repo.NewIndexFile()
+	}
+
+	existing.SortEntries()
+	r.printIndexDiff(DiffIndex(existing, indexFile))
+	return nil
+}
+
+// releaseDigest matches the SHA-256 digest of the chart package in release
+// notes, which is expected on a line of its own labelled "Digest:", e.g.
+// "Digest: {{ .Digest }}" in the release notes template. Other digests in the
+// notes, e.g. of commits or images, are not mistaken for it.
+var releaseDigest = regexp.MustCompile(`(?m)^[\t ]*Digest:[\t ]*(?:sha256:)?([0-9a-f]{64})[\t ]*\r?$`)
+
+// expectedDigest returns the digest the chart package asset of the release is
+// expected to have. It is read from a <package>.sha256 sidecar asset in the
+// format of sha256sum if the release has one, and from the release notes
+// otherwise. An empty digest is returned if neither records one.
+func (r *Releaser) expectedDigest(ctx context.Context, release *github.Release, asset *github.Asset) (string, error) {
+	for _, sidecar := range release.Assets {
+		if sidecar.Path != asset.Path+".sha256" {
+			continue
+		}
+		resp, err := r.httpClient.Get(ctx, sidecar.URL, nil)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", errors.Errorf("failed to download %s: %s", sidecar.URL, resp.Status)
+		}
+		content, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		fields := strings.Fields(string(content))
+		if len(fields) == 0 {
+			return "", errors.Errorf("%s does not contain a digest", sidecar.URL)
+		}
+		return fields[0], nil
+	}
+	if match := releaseDigest.FindStringSubmatch(release.Description); match != nil {
+		return match[1], nil
+	}
+	return "", nil
+}
+
+// downloadVerified downloads the file at the URL and compares its digest with
+// the expected one, which is skipped if it is empty. The file is removed if the
+// digests do not match.
+func (r *Releaser) downloadVerified(ctx context.Context, url string, file string, expected string) error {
+	resp, err := r.httpClient.Get(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if expected == "" {
+		return nil
+	}
+
+	actual, err := provenance.DigestFile(file)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		os.Remove(file)
+		return errors.Errorf("digest of %s (%s) does not match the expected digest %s", url, actual, expected)
+	}
+	return nil
+}
+
+// Prune deletes the releases of chart versions which are not retained by the
+// retention policy, which is applied per chart. A version is retained if it is
+// one of the last prune-keep-last versions or if its release is younger than
+// prune-max-age. Releases without a chart package are left alone.
+func (r *Releaser) Prune(ctx context.Context) error {
+	if r.config.PruneKeepLast <= 0 && r.config.PruneMaxAge <= 0 {
+		return errors.New("no retention policy set, prune-keep-last or prune-max-age is required")
+	}
+
+	releases, err := r.github.ListReleases(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error listing releases")
+	}
+
+	pruned := r.releasesToPrune(releases, time.Now())
+	if len(pruned) == 0 {
+		r.logger.Printf("No releases to prune")
+		return nil
+	}
+
+	for _, p := range pruned {
+		fields := logging.Fields{"chart": p.chart, "version": p.version, "tag": p.release.Tag}
+		if r.config.DryRun {
+			r.printDryRun("delete-release", fields, "delete release tag=%s chart=%s version=%s", p.release.Tag, p.chart, p.version)
+			continue
+		}
+		r.logger.Event("delete-release", fields, "Deleting release %s", p.release.Tag)
+		if err := r.github.DeleteRelease(ctx, p.release); err != nil {
+			return errors.Wrapf(err, "error deleting release %s", p.release.Tag)
+		}
+	}
+
+	if r.config.PruneIndex {
+		return r.pruneIndexFile(ctx, pruned)
+	}
+	return nil
+}
+
+// releasesToPrune applies the retention policy to the given releases.
+func (r *Releaser) releasesToPrune(releases []*github.Release, now time.Time) []prunedRelease {
+	var charts []string
+	versions := make(map[string][]prunedRelease)
+	for _, release := range releases {
+		for _, asset := range release.Assets {
+			name := filepath.Base(asset.Path)
+			if filepath.Ext(name) != ".tgz" {
+				continue
+			}
+			chartName, version, err := r.splitPackageNameAndVersion(strings.TrimSuffix(name, ".tgz"))
+			if err != nil {
+				continue
+			}
+			if _, ok := versions[chartName]; !ok {
+				charts = append(charts, chartName)
+			}
+			versions[chartName] = append(versions[chartName], prunedRelease{release, chartName, version})
+			break
+		}
+	}
+
+	var pruned []prunedRelease
+	for _, chartName := range charts {
+		releases := versions[chartName]
+		// newest version first, unparsable versions last
+		sort.SliceStable(releases, func(i, j int) bool {
+			vi, erri := semver.NewVersion(releases[i].version)
+			vj, errj := semver.NewVersion(releases[j].version)
+			if erri != nil || errj != nil {
+				return errj != nil && erri == nil
+			}
+			return vi.GreaterThan(vj)
+		})
+		for i, p := range releases {
+			keep := (r.config.PruneKeepLast > 0 && i < r.config.PruneKeepLast) ||
+				(r.config.PruneMaxAge > 0 && now.Sub(p.release.CreatedAt) < r.config.PruneMaxAge)
+			if !keep {
+				pruned = append(pruned, p)
+			}
+		}
+	}
+	return pruned
+}
+
+// pruneIndexFile removes the pruned chart versions from the index of the
+// charts repository.
+func (r *Releaser) pruneIndexFile(ctx context.Context, pruned []prunedRelease) error {
+	indexPath := r.config.IndexPath
+	if r.config.DryRun {
+		dir, err := ioutil.TempDir("", "chart-releaser-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		indexPath = filepath.Join(dir, "index.yaml")
+	}
+
+	exists, err := r.downloadIndexFile(ctx, indexPath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		r.logger.Printf("No index found at %s, skipping", r.remoteIndexURL())
+		return nil
+	}
+	indexFile, err := repo.LoadIndexFile(indexPath)
+	if err != nil {
+		return err
+	}
+
+	var update bool
+	for _, p := range pruned {
+		entries := indexFile.Entries[p.chart]
+		for i, entry := range entries {
+			if entry.Version != p.version {
+				continue
+			}
+			fields := logging.Fields{"chart": p.chart, "version": p.version}
+			if r.config.DryRun {
+				r.printDryRun("remove-from-index", fields, "index remove chart=%s version=%s", p.chart, p.version)
+			} else {
+				r.logger.Event("remove-from-index", fields, "Removing %s-%s from index", p.chart, p.version)
+			}
+			indexFile.Entries[p.chart] = append(entries[:i], entries[i+1:]...)
+			if len(indexFile.Entries[p.chart]) == 0 {
+				delete(indexFile.Entries, p.chart)
+			}
+			update = true
+			break
+		}
+	}
+
+	if !update || r.config.DryRun {
+		return nil
+	}
+
+	if indexFile.Generated, err = r.config.GeneratedTime(); err != nil {
+		return err
+	}
+	if err := r.writeIndexFile(ctx, indexFile); err != nil {
+		return err
+	}
+	return r.pushIndexFile("Remove pruned chart versions from index.yaml", nil)
+}
+
+// getListOfIndexPackages returns the chart packages of all package paths
+// together with their digests. A chart version found in several directories
+// is only returned once if the packages have the same digest, otherwise an
+// error is returned.
+func (r *Releaser) getListOfIndexPackages() ([]string, packageDigests, error) {
+	chartPackages, err := r.getListOfPathPackages()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(r.config.OnlyCharts) > 0 {
+		chartPackages = r.dropOtherCharts(chartPackages)
+	}
+
+	// The digests are needed here and again when adding the packages to the
+	// index, so they are computed once up front, in parallel.
+	digests, err := digestFiles(chartPackages, runtime.NumCPU())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var packages []string
+	versions := make(map[string]string)
+	for _, chartPackage := range chartPackages {
+		ch, err := loader.LoadFile(chartPackage)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s is not a helm chart package", chartPackage)
+		}
+		digest := digests[chartPackage]
+		key := ch.Metadata.Name + "-" + ch.Metadata.Version
+		if existing, ok := versions[key]; ok {
+			if existing != digest {
+				return nil, nil, errors.Errorf("%s %s is contained in several package paths with different content", ch.Metadata.Name, ch.Metadata.Version)
+			}
+			r.logger.Printf("Skipping %s, %s %s has already been found", chartPackage, ch.Metadata.Name, ch.Metadata.Version)
+			continue
+		}
+		versions[key] = digest
+		packages = append(packages, chartPackage)
+	}
+	return packages, digests, nil
+}
+
+// getListOfPathPackages returns the chart packages of all package paths.
+func (r *Releaser) getListOfPathPackages() ([]string, error) {
+	var chartPackages []string
+	for _, packagePath := range r.packagePaths() {
+		found, err := r.getListOfPackages(packagePath)
+		if err != nil {
+			return nil, err
+		}
+		chartPackages = append(chartPackages, found...)
+	}
+	return chartPackages, nil
+}
+
+// getListOfPackages returns the chart packages in dir and its subdirectories.
+// Only *.tgz files with a gzip header are returned, other files such as
+// provenance files or stray files left behind by CI caches are skipped.
 func (r *Releaser) getListOfPackages(dir string) ([]string, error) {
-	return filepath.Glob(filepath.Join(dir, "*.tgz"))
+	var packages []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".tgz":
+			ok, err := isGzipFile(path)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				r.logger.Printf("Skipping %s, it is not a gzip compressed chart package", path)
+				return nil
+			}
+			skip, err := r.skipChart(path)
+			if err != nil || skip {
+				return err
+			}
+			packages = append(packages, path)
+		case ".prov", ".sig", ".bundle":
+		default:
+			if !strings.HasSuffix(path, ".tgz"+sbom.Extension) {
+				r.logger.Printf("Skipping %s, it is not a chart package", path)
+			}
+		}
+		return nil
+	})
+	return packages, err
+}
+
+// dropDeprecated returns the chart packages without the ones of charts which
+// are marked as deprecated in Chart.yaml.
+func (r *Releaser) dropDeprecated(chartPackages []string) ([]string, error) {
+	var kept []string
+	for _, chartPackage := range chartPackages {
+		ch, err := loader.LoadFile(chartPackage)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s is not a helm chart package", chartPackage)
+		}
+		if ch.Metadata.Deprecated {
+			r.logger.Event("skip-deprecated", logging.Fields{"package": chartPackage, "chart": ch.Metadata.Name, "version": ch.Metadata.Version},
+				"Skipping %s, chart %s is deprecated", chartPackage, ch.Metadata.Name)
+			continue
+		}
+		kept = append(kept, chartPackage)
+	}
+	return kept, nil
+}
+
+// dropOtherCharts returns the chart packages of the charts listed in
+// only-charts, telling the chart name from the file name.
+func (r *Releaser) dropOtherCharts(chartPackages []string) []string {
+	var kept []string
+	for _, chartPackage := range chartPackages {
+		name, _, err := r.splitPackageNameAndVersion(strings.TrimSuffix(filepath.Base(chartPackage), ".tgz"))
+		if err == nil && !r.onlyChart(name) {
+			r.logger.Event("skip-chart", logging.Fields{"package": chartPackage, "chart": name},
+				"Skipping %s, chart %s is not one of only-charts", chartPackage, name)
+			continue
+		}
+		kept = append(kept, chartPackage)
+	}
+	return kept
+}
+
+// onlyChart reports whether the entries of the chart of the given name are
+// updated, which are all of them unless only-charts is set. The published name
+// of a listed chart matches as well.
+func (r *Releaser) onlyChart(name string) bool {
+	if len(r.config.OnlyCharts) == 0 {
+		return true
+	}
+	for _, only := range r.config.OnlyCharts {
+		if name == only || name == r.publishedName(only) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipChart reports whether the name of the chart in the given package, taken
+// from its file name, matches any of the skip-charts glob patterns. Matches are
+// logged.
+func (r *Releaser) skipChart(chartPackage string) (bool, error) {
+	if len(r.config.SkipCharts) == 0 {
+		return false, nil
+	}
+	name, _, err := r.splitPackageNameAndVersion(strings.TrimSuffix(filepath.Base(chartPackage), ".tgz"))
+	if err != nil {
+		// reported when the package names are verified
+		return false, nil
+	}
+	for _, pattern := range r.config.SkipCharts {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid skip-charts pattern %q", pattern)
+		}
+		if ok {
+			r.logger.Event("skip-chart", logging.Fields{"package": chartPackage, "chart": name, "pattern": pattern},
+				"Skipping %s, chart %s matches skip pattern %s", chartPackage, name, pattern)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isGzipFile reports whether the file starts with the gzip magic number.
+func isGzipFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(f, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return header[0] == 0x1f && header[1] == 0x8b, nil
 }
 
 func copyFile(srcFile string, dstFile string) error {
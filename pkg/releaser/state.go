@@ -0,0 +1,124 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// runState records the chart versions released by a run of CreateReleases in
+// the state file, so that a run which was interrupted can be resumed without
+// releasing them again. Chart versions are keyed by their digest as well, so
+// that a package which was rebuilt in the meantime is released again.
+type runState struct {
+	mu       sync.Mutex
+	path     string
+	released map[stateEntry]bool
+}
+
+// stateEntry is a chart version recorded in the state file.
+type stateEntry struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Digest  string `json:"digest"`
+}
+
+// stateFile is the content of the state file.
+type stateFile struct {
+	Released []stateEntry `json:"released"`
+}
+
+// loadRunState loads the state file at the given path. A missing state file is
+// an empty state, i.e. a run which starts from scratch.
+func loadRunState(path string) (*runState, error) {
+	state := &runState{path: path, released: map[stateEntry]bool{}}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading state file")
+	}
+	var file stateFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, errors.Wrapf(err, "error parsing state file %s", path)
+	}
+	for _, entry := range file.Released {
+		state.released[entry] = true
+	}
+	return state, nil
+}
+
+// done reports whether the chart version with the given digest has been
+// released already.
+func (s *runState) done(entry stateEntry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.released[entry]
+}
+
+// record adds the released chart version to the state and writes the state
+// file. The file is replaced atomically, so that it stays intact if the run is
+// killed while writing it.
+func (s *runState) record(entry stateEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.released[entry] = true
+
+	file := stateFile{Released: make([]stateEntry, 0, len(s.released))}
+	for entry := range s.released {
+		file.Released = append(file.Released, entry)
+	}
+	sort.Slice(file.Released, func(i, j int) bool {
+		a, b := file.Released[i], file.Released[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	content, err := json.MarshalIndent(&file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// remove deletes the state file once the run has completed.
+func (s *runState) remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/github"
+)
+
+func TestReleaser_CreateReleasesResumesFromStateFile(t *testing.T) {
+	packagePath := t.TempDir()
+	for _, p := range []string{"testdata/release-packages/test-chart-0.1.0.tgz", "testdata/other-packages/other-chart-0.1.0.tgz"} {
+		assert.NoError(t, copyFile(p, filepath.Join(packagePath, filepath.Base(p))))
+	}
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	newReleaser := func(fakeGitHub *FakeGitHub) *Releaser {
+		return &Releaser{
+			config: &config.Options{
+				PackagePath:          packagePath,
+				ReleaseNameTemplate:  "{{ .Name }}-{{ .Version }}",
+				AllowChangedVersions: true,
+				StateFile:            stateFile,
+			},
+			github: fakeGitHub,
+		}
+	}
+	tagged := func(tag string) interface{} {
+		return mock.MatchedBy(func(release *github.Release) bool { return release.Tag == tag })
+	}
+
+	// the first run is interrupted after releasing test-chart
+	fakeGitHub := new(FakeGitHub)
+	fakeGitHub.On("CreateRelease", mock.Anything, tagged("other-chart-0.1.0")).Return(errors.New("connection reset"))
+	fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return(nil)
+	assert.Error(t, newReleaser(fakeGitHub).CreateReleases(context.Background()))
+	assert.FileExists(t, stateFile)
+
+	state, err := loadRunState(stateFile)
+	assert.NoError(t, err)
+	assert.Len(t, state.released, 1)
+	for entry := range state.released {
+		assert.Equal(t, "test-chart", entry.Name)
+		assert.Equal(t, "0.1.0", entry.Version)
+		assert.NotEmpty(t, entry.Digest)
+	}
+
+	// the second run only releases other-chart and removes the state file
+	fakeGitHub = new(FakeGitHub)
+	fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return(nil)
+	assert.NoError(t, newReleaser(fakeGitHub).CreateReleases(context.Background()))
+	fakeGitHub.AssertNumberOfCalls(t, "CreateRelease", 1)
+	fakeGitHub.AssertCalled(t, "CreateRelease", mock.Anything, tagged("other-chart-0.1.0"))
+	assert.NoFileExists(t, stateFile)
+}
+
+func TestReleaser_CreateReleasesStateFileChangedPackage(t *testing.T) {
+	packagePath := t.TempDir()
+	assert.NoError(t, copyFile("testdata/release-packages/test-chart-0.1.0.tgz", filepath.Join(packagePath, "test-chart-0.1.0.tgz")))
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	// recorded with the digest of a previous build of the package
+	assert.NoError(t, ioutil.WriteFile(stateFile, []byte(`{"released": [{"name": "test-chart", "version": "0.1.0", "digest": "0123"}]}`), 0644))
+
+	fakeGitHub := new(FakeGitHub)
+	fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return(nil)
+	r := &Releaser{
+		config: &config.Options{
+			PackagePath:          packagePath,
+			ReleaseNameTemplate:  "{{ .Name }}-{{ .Version }}",
+			AllowChangedVersions: true,
+			StateFile:            stateFile,
+		},
+		github: fakeGitHub,
+	}
+	assert.NoError(t, r.CreateReleases(context.Background()))
+	fakeGitHub.AssertNumberOfCalls(t, "CreateRelease", 1)
+	assert.NoFileExists(t, stateFile)
+
+	// a corrupt state file is an error rather than starting over
+	assert.NoError(t, ioutil.WriteFile(stateFile, []byte(`{`), 0644))
+	assert.Error(t, r.CreateReleases(context.Background()))
+}
@@ -0,0 +1,160 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helm/chart-releaser/pkg/github"
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// runStats collects the wall time of the phases of a run from the progress
+// of the chart versions. With several releases created in parallel, the wall
+// time of a phase is the time from its first start to its last end.
+type runStats struct {
+	mu      sync.Mutex
+	start   time.Time
+	phases  map[Phase]*phaseSpan
+	running map[string]time.Time
+}
+
+func newRunStats() *runStats {
+	return &runStats{start: time.Now(), phases: map[Phase]*phaseSpan{}, running: map[string]time.Time{}}
+}
+
+type phaseSpan struct {
+	start time.Time
+	end   time.Time
+}
+
+// phaseOrder is the order phases are summarized in.
+var phaseOrder = []Phase{PhasePackage, PhaseRelease, PhaseIndex}
+
+// record records the start or end of a phase of a chart version and returns
+// how long the phase took at its end.
+func (s *runStats) record(event ProgressEvent) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := fmt.Sprintf("%s/%s-%s", event.Phase, event.Chart, event.Version)
+	span, ok := s.phases[event.Phase]
+	if !ok {
+		span = &phaseSpan{start: now}
+		s.phases[event.Phase] = span
+	}
+	if !event.Done {
+		s.running[key] = now
+		return 0
+	}
+	span.end = now
+	started, ok := s.running[key]
+	if !ok {
+		return 0
+	}
+	delete(s.running, key)
+	return now.Sub(started)
+}
+
+// runSummary summarizes the releases created by a run.
+type runSummary struct {
+	Released      int
+	Skipped       int
+	Failed        int
+	BytesUploaded int64
+	Duration      time.Duration
+	Phases        map[Phase]time.Duration
+}
+
+// recordProgress records the progress event in the statistics of the run and
+// logs the time the phase took if verbose output is enabled.
+func (r *Releaser) recordProgress(event ProgressEvent) {
+	took := r.stats.record(event)
+	if event.Done && r.config.Verbose {
+		r.logger.Event("timing", logging.Fields{"chart": event.Chart, "version": event.Version, "phase": event.Phase, "duration_ms": took.Milliseconds()},
+			"%s %s %s took %s", event.Phase, event.Chart, event.Version, took.Round(time.Millisecond))
+	}
+}
+
+// summarize returns the summary of the run and starts a new one. Chart
+// versions without a released chart and without an error were skipped.
+func (r *Releaser) summarize(released []*releasedChart, errs []error, skipped int) *runSummary {
+	stats := r.stats
+	if stats != nil {
+		r.stats = newRunStats()
+	}
+
+	summary := &runSummary{Skipped: skipped, Phases: map[Phase]time.Duration{}}
+	for i := range released {
+		switch {
+		case errs[i] != nil:
+			summary.Failed++
+		case released[i] == nil || released[i].skipped:
+			summary.Skipped++
+		default:
+			summary.Released++
+			summary.BytesUploaded += released[i].bytes
+		}
+	}
+	if stats != nil {
+		summary.Duration = time.Since(stats.start)
+		for phase, span := range stats.phases {
+			if !span.end.IsZero() {
+				summary.Phases[phase] = span.end.Sub(span.start)
+			}
+		}
+	}
+	return summary
+}
+
+// printSummary logs the summary of the run.
+func (r *Releaser) printSummary(summary *runSummary) {
+	fields := logging.Fields{
+		"released":       summary.Released,
+		"skipped":        summary.Skipped,
+		"failed":         summary.Failed,
+		"bytes_uploaded": summary.BytesUploaded,
+		"duration_ms":    summary.Duration.Milliseconds(),
+	}
+	var phases []string
+	for _, phase := range phaseOrder {
+		if d, ok := summary.Phases[phase]; ok {
+			fields[string(phase)+"_ms"] = d.Milliseconds()
+			phases = append(phases, fmt.Sprintf("%s %s", phase, d.Round(time.Millisecond)))
+		}
+	}
+	var timing string
+	if len(phases) > 0 {
+		timing = " (" + strings.Join(phases, ", ") + ")"
+	}
+	r.logger.Event("summary", fields, "Released %d, skipped %d and failed %d charts, uploaded %d bytes in %s%s",
+		summary.Released, summary.Skipped, summary.Failed, summary.BytesUploaded, summary.Duration.Round(time.Millisecond), timing)
+}
+
+// assetBytes returns the total size of the files of the assets.
+func assetBytes(assets []*github.Asset) int64 {
+	var total int64
+	for _, asset := range assets {
+		if info, err := os.Stat(asset.Path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
@@ -0,0 +1,97 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/helm/chart-releaser/pkg/config"
+	"github.com/helm/chart-releaser/pkg/github"
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+func TestReleaser_CreateReleasesSummary(t *testing.T) {
+	packagePath := t.TempDir()
+	for _, p := range []string{"testdata/release-packages/test-chart-0.1.0.tgz", "testdata/other-packages/other-chart-0.1.0.tgz"} {
+		assert.NoError(t, copyFile(p, filepath.Join(packagePath, filepath.Base(p))))
+	}
+	ch, err := loader.LoadFile("testdata/release-packages/test-chart-0.1.0.tgz")
+	assert.NoError(t, err)
+	ch.Metadata.Name = "deprecated-chart"
+	ch.Metadata.Deprecated = true
+	_, err = chartutil.Save(ch, packagePath)
+	assert.NoError(t, err)
+	info, err := os.Stat("testdata/release-packages/test-chart-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	logger, err := logging.New(logging.FormatJSON, &out)
+	assert.NoError(t, err)
+	fakeGitHub := new(FakeGitHub)
+	fakeGitHub.On("CreateRelease", mock.Anything, mock.MatchedBy(func(release *github.Release) bool {
+		return release.Tag == "other-chart-0.1.0"
+	})).Return(errors.New("server error"))
+	fakeGitHub.On("CreateRelease", mock.Anything, mock.Anything).Return(nil)
+	r := &Releaser{
+		config: &config.Options{
+			PackagePath:          packagePath,
+			ReleaseNameTemplate:  "{{ .Name }}-{{ .Version }}",
+			AllowChangedVersions: true,
+			SkipDeprecated:       true,
+			Verbose:              true,
+		},
+		github: fakeGitHub,
+		logger: logger,
+		stats:  newRunStats(),
+	}
+	assert.Error(t, r.CreateReleases(context.Background()))
+
+	events := map[string][]map[string]interface{}{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var event map[string]interface{}
+		assert.NoError(t, decoder.Decode(&event))
+		action, _ := event["action"].(string)
+		events[action] = append(events[action], event)
+	}
+
+	assert.Len(t, events["timing"], 2)
+	for _, event := range events["timing"] {
+		assert.Equal(t, string(PhaseRelease), event["phase"])
+		assert.Contains(t, event, "duration_ms")
+	}
+
+	if assert.Len(t, events["summary"], 1) {
+		summary := events["summary"][0]
+		assert.Equal(t, float64(1), summary["released"])
+		assert.Equal(t, float64(1), summary["skipped"])
+		assert.Equal(t, float64(1), summary["failed"])
+		assert.Equal(t, float64(info.Size()), summary["bytes_uploaded"])
+		assert.Contains(t, summary, "duration_ms")
+		assert.Contains(t, summary, "release_ms")
+		assert.NotContains(t, summary, "package_ms")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"sync"
+)
+
+// worktrees keeps track of the worktrees created by a releaser and its copies
+// which have not been removed yet.
+type worktrees struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// addWorktree creates a worktree of the given committish and keeps track of
+// it until it is removed with removeWorktree or Cleanup.
+func (r *Releaser) addWorktree(committish string) (string, error) {
+	worktree, err := r.git.AddWorktree("", committish)
+	if err != nil {
+		return "", err
+	}
+	if r.worktrees == nil {
+		r.worktrees = &worktrees{}
+	}
+	r.worktrees.mu.Lock()
+	r.worktrees.paths = append(r.worktrees.paths, worktree)
+	r.worktrees.mu.Unlock()
+	return worktree, nil
+}
+
+// removeWorktree removes the worktree created by addWorktree. It is deferred
+// right after creating the worktree, so that it is removed even if the run
+// fails or panics. Failures are only logged, the worktree is left to Cleanup.
+func (r *Releaser) removeWorktree(worktree string) {
+	if err := r.git.RemoveWorktree("", worktree); err != nil {
+		r.logger.Event("remove-worktree-failed", nil, "Warning: failed to remove worktree %s: %s", worktree, err)
+		return
+	}
+	if r.worktrees == nil {
+		return
+	}
+	r.worktrees.mu.Lock()
+	defer r.worktrees.mu.Unlock()
+	for i, path := range r.worktrees.paths {
+		if path == worktree {
+			r.worktrees.paths = append(r.worktrees.paths[:i], r.worktrees.paths[i+1:]...)
+			break
+		}
+	}
+}
+
+// Cleanup removes the worktrees of the releaser which have not been removed
+// yet. Worktrees are removed as soon as they are no longer needed, even if the
+// run fails, so this is a safety net for library users, who can defer it right
+// after creating the releaser. It is safe to call it several times.
+func (r *Releaser) Cleanup() error {
+	if r.worktrees == nil {
+		return nil
+	}
+	r.worktrees.mu.Lock()
+	paths := r.worktrees.paths
+	r.worktrees.paths = nil
+	r.worktrees.mu.Unlock()
+
+	var failed errorList
+	for _, worktree := range paths {
+		if err := r.git.RemoveWorktree("", worktree); err != nil {
+			failed = append(failed, err)
+			r.worktrees.mu.Lock()
+			r.worktrees.paths = append(r.worktrees.paths, worktree)
+			r.worktrees.mu.Unlock()
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package releaser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/helm/chart-releaser/pkg/config"
+)
+
+func TestReleaser_UpdateIndexFileRemovesWorktree(t *testing.T) {
+	tests := []struct {
+		name        string
+		commitErr   error
+		commitPanic bool
+	}{
+		{name: "error", commitErr: errors.New("commit failed")},
+		{name: "panic", commitPanic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worktree := t.TempDir()
+			fakeGit := &FakeGit{worktree: worktree, commitErr: tt.commitErr, commitPanic: tt.commitPanic}
+			fakeGit.On("GetRemoteURL", "origin").Return()
+			fakeGit.On("AddWorktree", "", "origin/gh-pages").Return()
+			fakeGit.On("RemoveWorktree", "", worktree).Return()
+			fakeGit.On("Add", worktree, mock.Anything).Return()
+			fakeGit.On("Commit", worktree, mock.Anything).Return()
+			r := &Releaser{
+				config: &config.Options{
+					Owner:       "owner",
+					GitRepo:     "repo",
+					IndexPath:   filepath.Join(t.TempDir(), "index.yaml"),
+					PackagePath: "testdata/release-packages",
+					Token:       "token",
+					Remote:      "origin",
+					PagesBranch: "gh-pages",
+					Push:        true,
+				},
+				github:     new(FakeGitHub),
+				httpClient: &MockClient{http.StatusNotFound, ""},
+				git:        fakeGit,
+			}
+
+			func() {
+				defer func() {
+					assert.Equal(t, tt.commitPanic, recover() != nil)
+				}()
+				_, err := r.UpdateIndexFile(context.Background())
+				assert.EqualError(t, err, "commit failed")
+			}()
+			fakeGit.AssertNumberOfCalls(t, "RemoveWorktree", 1)
+
+			// nothing is left for cleaning up
+			assert.NoError(t, r.Cleanup())
+			fakeGit.AssertNumberOfCalls(t, "RemoveWorktree", 1)
+		})
+	}
+}
+
+func TestReleaser_Cleanup(t *testing.T) {
+	worktree := t.TempDir()
+	fakeGit := &FakeGit{worktree: worktree}
+	fakeGit.On("AddWorktree", "", "origin/gh-pages").Return()
+	fakeGit.On("RemoveWorktree", "", worktree).Return()
+	r := &Releaser{config: &config.Options{}, git: fakeGit}
+
+	// nothing to clean up yet
+	assert.NoError(t, r.Cleanup())
+	fakeGit.AssertNotCalled(t, "RemoveWorktree", "", worktree)
+
+	// a worktree whose user stopped before removing it
+	_, err := r.addWorktree("origin/gh-pages")
+	assert.NoError(t, err)
+	assert.NoError(t, r.Cleanup())
+	fakeGit.AssertNumberOfCalls(t, "RemoveWorktree", 1)
+
+	assert.NoError(t, r.Cleanup())
+	fakeGit.AssertNumberOfCalls(t, "RemoveWorktree", 1)
+}
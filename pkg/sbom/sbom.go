@@ -0,0 +1,232 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+
+	"github.com/helm/chart-releaser/pkg/version"
+)
+
+// Extension is appended to the file name of a chart package to get the file
+// name of its SBOM.
+const Extension = ".cdx.json"
+
+// Generator writes CycloneDX software bills of materials for chart packages.
+// They list the dependency charts and the container images referenced in the
+// values of the chart and its dependencies.
+type Generator struct{}
+
+type bom struct {
+	BOMFormat    string       `json:"bomFormat"`
+	SpecVersion  string       `json:"specVersion"`
+	Version      int          `json:"version"`
+	Metadata     metadata     `json:"metadata"`
+	Components   []component  `json:"components"`
+	Dependencies []dependency `json:"dependencies"`
+}
+
+type metadata struct {
+	Timestamp string    `json:"timestamp"`
+	Tools     []tool    `json:"tools"`
+	Component component `json:"component"`
+}
+
+type tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type component struct {
+	BOMRef      string     `json:"bom-ref"`
+	Type        string     `json:"type"`
+	Name        string     `json:"name"`
+	Version     string     `json:"version,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Properties  []property `json:"properties,omitempty"`
+}
+
+type property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// Generate writes the SBOM of the given chart package to the given path.
+func (g *Generator) Generate(chartPackage string, sbom string) error {
+	ch, err := loader.LoadFile(chartPackage)
+	if err != nil {
+		return errors.Wrapf(err, "%s is not a helm chart package", chartPackage)
+	}
+
+	data, err := json.MarshalIndent(newBOM(ch, time.Now()), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(sbom, append(data, '\n'), 0644)
+}
+
+// newBOM returns the SBOM of the given chart. Charts and images are listed
+// once, sorted by reference.
+func newBOM(ch *chart.Chart, now time.Time) *bom {
+	root := chartComponent(ch.Metadata.Name, ch.Metadata.Version, "")
+	root.Description = ch.Metadata.Description
+
+	components := make(map[string]component)
+	var dependsOn []string
+	for _, d := range ch.Metadata.Dependencies {
+		c := chartComponent(d.Name, dependencyVersion(ch, d), d.Repository)
+		if _, ok := components[c.BOMRef]; !ok {
+			dependsOn = append(dependsOn, c.BOMRef)
+		}
+		components[c.BOMRef] = c
+	}
+	for _, image := range chartImages(ch) {
+		c := imageComponent(image)
+		if _, ok := components[c.BOMRef]; !ok {
+			dependsOn = append(dependsOn, c.BOMRef)
+		}
+		components[c.BOMRef] = c
+	}
+
+	result := &bom{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: metadata{
+			Timestamp: now.UTC().Format(time.RFC3339),
+			Tools:     []tool{{Name: "chart-releaser", Version: version.Version()}},
+			Component: root,
+		},
+		Components:   []component{},
+		Dependencies: []dependency{{Ref: root.BOMRef, DependsOn: []string{}}},
+	}
+	sort.Strings(dependsOn)
+	for _, ref := range dependsOn {
+		result.Components = append(result.Components, components[ref])
+		result.Dependencies[0].DependsOn = append(result.Dependencies[0].DependsOn, ref)
+	}
+	return result
+}
+
+func chartComponent(name string, version string, repository string) component {
+	c := component{
+		BOMRef:  "chart:" + name + "@" + version,
+		Type:    "application",
+		Name:    name,
+		Version: version,
+	}
+	if repository != "" {
+		c.Properties = []property{{Name: "helm:repository", Value: repository}}
+	}
+	return c
+}
+
+func imageComponent(image string) component {
+	name, version := image, ""
+	if i := strings.Index(image, "@"); i >= 0 {
+		name, version = image[:i], image[i+1:]
+	} else if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		name, version = image[:i], image[i+1:]
+	}
+	return component{
+		BOMRef:  "image:" + image,
+		Type:    "container",
+		Name:    name,
+		Version: version,
+	}
+}
+
+// dependencyVersion returns the version of the packaged dependency chart, or
+// the version constraint of the dependency if it is not packaged.
+func dependencyVersion(ch *chart.Chart, d *chart.Dependency) string {
+	for _, sub := range ch.Dependencies() {
+		if sub.Metadata.Name == d.Name {
+			return sub.Metadata.Version
+		}
+	}
+	return d.Version
+}
+
+// chartImages returns the container images referenced in the values of the
+// chart and its packaged dependencies.
+func chartImages(ch *chart.Chart) []string {
+	var images []string
+	collectImages(ch.Values, ch.Metadata.AppVersion, &images)
+	for _, sub := range ch.Dependencies() {
+		images = append(images, chartImages(sub)...)
+	}
+	return images
+}
+
+// collectImages walks the values looking for the common ways charts define
+// images: a string value of an "image" key, e.g. "nginx:1.25", or a map with
+// a "repository" and optionally a "registry", "tag" or "digest". The tag
+// defaults to the app version of the chart, as is custom in chart templates.
+func collectImages(values interface{}, appVersion string, images *[]string) {
+	switch v := values.(type) {
+	case map[string]interface{}:
+		if image, ok := imageReference(v, appVersion); ok {
+			*images = append(*images, image)
+		}
+		for key, value := range v {
+			if s, ok := value.(string); ok && key == "image" && s != "" {
+				*images = append(*images, s)
+				continue
+			}
+			collectImages(value, appVersion, images)
+		}
+	case []interface{}:
+		for _, value := range v {
+			collectImages(value, appVersion, images)
+		}
+	}
+}
+
+func imageReference(values map[string]interface{}, appVersion string) (string, bool) {
+	repository, _ := values["repository"].(string)
+	if repository == "" {
+		return "", false
+	}
+	image := repository
+	if registry, _ := values["registry"].(string); registry != "" {
+		image = strings.TrimSuffix(registry, "/") + "/" + repository
+	}
+	if digest, _ := values["digest"].(string); digest != "" {
+		return image + "@" + digest, true
+	}
+	// unquoted tags such as 1.25 are parsed as numbers
+	tag := appVersion
+	if t, ok := values["tag"]; ok && t != nil && t != "" {
+		tag = fmt.Sprint(t)
+	}
+	if tag != "" {
+		image += ":" + tag
+	}
+	return image, true
+}
@@ -0,0 +1,99 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestNewBOM(t *testing.T) {
+	sub := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "redis", Version: "17.3.2", AppVersion: "7.0.5"},
+		Values: map[string]interface{}{
+			"image": map[string]interface{}{"registry": "docker.io", "repository": "bitnami/redis", "tag": "7.0.5"},
+		},
+	}
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{
+			Name:        "app",
+			Version:     "1.2.0",
+			AppVersion:  "2.0.0",
+			Description: "An app",
+			Dependencies: []*chart.Dependency{
+				{Name: "redis", Version: "17.x.x", Repository: "https://charts.bitnami.com/bitnami"},
+				{Name: "postgresql", Version: "12.1.0", Repository: "https://charts.bitnami.com/bitnami"},
+			},
+		},
+		Values: map[string]interface{}{
+			"image": map[string]interface{}{"repository": "example/app"},
+			"sidecars": []interface{}{
+				map[string]interface{}{"name": "proxy", "image": "envoyproxy/envoy:v1.24.0"},
+				map[string]interface{}{"name": "pinned", "image": map[string]interface{}{"repository": "busybox", "digest": "sha256:abc"}},
+			},
+			"metrics": map[string]interface{}{
+				"image": map[string]interface{}{"repository": "example/exporter", "tag": 1.5},
+			},
+		},
+	}
+	ch.AddDependency(sub)
+
+	now := time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)
+	b := newBOM(ch, now)
+	assert.Equal(t, "CycloneDX", b.BOMFormat)
+	assert.Equal(t, "2022-11-01T12:00:00Z", b.Metadata.Timestamp)
+	assert.Equal(t, component{BOMRef: "chart:app@1.2.0", Type: "application", Name: "app", Version: "1.2.0", Description: "An app"}, b.Metadata.Component)
+
+	var refs []string
+	versions := make(map[string]string)
+	for _, c := range b.Components {
+		refs = append(refs, c.BOMRef)
+		versions[c.Name] = c.Version
+	}
+	expected := []string{
+		"chart:postgresql@12.1.0",
+		"chart:redis@17.3.2",
+		"image:busybox@sha256:abc",
+		"image:docker.io/bitnami/redis:7.0.5",
+		"image:envoyproxy/envoy:v1.24.0",
+		"image:example/app:2.0.0",
+		"image:example/exporter:1.5",
+	}
+	assert.Equal(t, expected, refs)
+	assert.Equal(t, "sha256:abc", versions["busybox"])
+	assert.Equal(t, "v1.24.0", versions["envoyproxy/envoy"])
+	assert.Equal(t, []dependency{{Ref: "chart:app@1.2.0", DependsOn: expected}}, b.Dependencies)
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	sbom := filepath.Join(t.TempDir(), "test-chart-0.1.0.tgz"+Extension)
+	require.NoError(t, new(Generator).Generate("../releaser/testdata/release-packages/test-chart-0.1.0.tgz", sbom))
+
+	data, err := ioutil.ReadFile(sbom)
+	require.NoError(t, err)
+	var b bom
+	require.NoError(t, json.Unmarshal(data, &b))
+	assert.Equal(t, "test-chart", b.Metadata.Component.Name)
+	assert.Equal(t, "0.1.0", b.Metadata.Component.Version)
+
+	assert.Error(t, new(Generator).Generate("sbom.go", sbom))
+}
@@ -0,0 +1,119 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// gcsBucket is the part of the GCS API used by the GCS backend
+type gcsBucket interface {
+	Attrs(ctx context.Context, name string) (*gcs.ObjectAttrs, error)
+	Write(ctx context.Context, name string, contentType string, r io.Reader) error
+}
+
+type gcsBucketHandle struct {
+	*gcs.BucketHandle
+}
+
+func (b *gcsBucketHandle) Attrs(ctx context.Context, name string) (*gcs.ObjectAttrs, error) {
+	return b.Object(name).Attrs(ctx)
+}
+
+func (b *gcsBucketHandle) Write(ctx context.Context, name string, contentType string, r io.Reader) error {
+	w := b.Object(name).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// GCS publishes to a Google Cloud Storage bucket
+type GCS struct {
+	bucket  gcsBucket
+	name    string
+	prefix  string
+	baseURL string
+	logger  *logging.Logger
+}
+
+// NewGCS creates a backend for the given bucket. Credentials are looked up
+// using Application Default Credentials. If baseURL is empty, objects are
+// expected to be served from the public URL of the bucket. The uploads are
+// logged with logger.
+func NewGCS(bucket, prefix, baseURL string, logger *logging.Logger) (*GCS, error) {
+	client, err := gcs.NewClient(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GCS client")
+	}
+
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket, prefix)
+	}
+
+	return &GCS{
+		bucket:  &gcsBucketHandle{client.Bucket(bucket)},
+		name:    bucket,
+		prefix:  prefix,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		logger:  logger,
+	}, nil
+}
+
+// Upload uploads the local file as object with the given name. Objects that
+// already exist with the same content are left untouched.
+func (g *GCS) Upload(ctx context.Context, name string, file string, contentType string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	object := objectName(g.prefix, name)
+	sum := md5.Sum(content)
+	attrs, err := g.bucket.Attrs(ctx, object)
+	if err == nil && bytes.Equal(attrs.MD5, sum[:]) {
+		g.logger.Event("storage-skip-upload", logging.Fields{"file": file, "url": fmt.Sprintf("gs://%s/%s", g.name, object)},
+			"gs://%s/%s is up to date, skipping upload", g.name, object)
+		return nil
+	}
+	if err != nil && err != gcs.ErrObjectNotExist {
+		return errors.Wrapf(err, "failed to get attributes of gs://%s/%s", g.name, object)
+	}
+
+	g.logger.Event("storage-upload", logging.Fields{"file": file, "url": fmt.Sprintf("gs://%s/%s", g.name, object)},
+		"Uploading %s to gs://%s/%s", file, g.name, object)
+	if err := g.bucket.Write(ctx, object, contentType, bytes.NewReader(content)); err != nil {
+		return errors.Wrapf(err, "failed to upload %s to gs://%s/%s", file, g.name, object)
+	}
+	return nil
+}
+
+// BaseURL returns the URL under which the uploaded objects are served
+func (g *GCS) BaseURL() string {
+	return g.baseURL
+}
@@ -0,0 +1,125 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+type gcsObject struct {
+	contentType string
+	body        string
+}
+
+type FakeGCSBucket struct {
+	objects map[string]gcsObject
+	writes  int
+}
+
+func (f *FakeGCSBucket) Attrs(ctx context.Context, name string) (*gcs.ObjectAttrs, error) {
+	object, ok := f.objects[name]
+	if !ok {
+		return nil, gcs.ErrObjectNotExist
+	}
+	sum := md5.Sum([]byte(object.body))
+	return &gcs.ObjectAttrs{Name: name, ContentType: object.contentType, MD5: sum[:]}, nil
+}
+
+func (f *FakeGCSBucket) Write(ctx context.Context, name string, contentType string, r io.Reader) error {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.objects[name] = gcsObject{contentType, string(body)}
+	f.writes++
+	return nil
+}
+
+func TestGCS_Upload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "index.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("apiVersion: v1\n"), 0644))
+
+	tests := []struct {
+		name       string
+		prefix     string
+		existing   map[string]gcsObject
+		object     string
+		wantWrites int
+	}{
+		{
+			"new-object",
+			"",
+			map[string]gcsObject{},
+			"index.yaml",
+			1,
+		},
+		{
+			"prefix",
+			"charts/",
+			map[string]gcsObject{},
+			"charts/index.yaml",
+			1,
+		},
+		{
+			"changed-object",
+			"",
+			map[string]gcsObject{"index.yaml": {ContentTypeIndex, "apiVersion: v0\n"}},
+			"index.yaml",
+			1,
+		},
+		{
+			"unchanged-object",
+			"",
+			map[string]gcsObject{"index.yaml": {ContentTypeIndex, "apiVersion: v1\n"}},
+			"index.yaml",
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := &FakeGCSBucket{objects: tt.existing}
+			var out bytes.Buffer
+			logger, err := logging.New(logging.FormatJSON, &out)
+			assert.NoError(t, err)
+			g := &GCS{bucket: bucket, name: "bucket", prefix: tt.prefix, logger: logger}
+			err = g.Upload(context.Background(), "index.yaml", file, ContentTypeIndex)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantWrites, bucket.writes)
+			assert.Equal(t, gcsObject{ContentTypeIndex, "apiVersion: v1\n"}, bucket.objects[tt.object])
+
+			// uploads are logged in the format of the logger
+			var event map[string]interface{}
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &event))
+			if tt.wantWrites == 0 {
+				assert.Equal(t, "storage-skip-upload", event["action"])
+			} else {
+				assert.Equal(t, "storage-upload", event["action"])
+			}
+			assert.Equal(t, "gs://bucket/"+tt.object, event["url"])
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/pkg/errors"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+// S3 publishes to an S3 bucket
+type S3 struct {
+	client  s3iface.S3API
+	bucket  string
+	prefix  string
+	baseURL string
+	logger  *logging.Logger
+}
+
+// NewS3 creates a backend for the given bucket. Credentials are looked up
+// using the default credential chain of the AWS SDK. If baseURL is empty,
+// objects are expected to be served from the public URL of the bucket. The
+// uploads are logged with logger.
+func NewS3(bucket, prefix, region, baseURL string, logger *logging.Logger) (*S3, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Region: aws.String(region)},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create AWS session")
+	}
+
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, aws.StringValue(sess.Config.Region), prefix)
+	}
+
+	return &S3{
+		client:  s3.New(sess),
+		bucket:  bucket,
+		prefix:  prefix,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		logger:  logger,
+	}, nil
+}
+
+// Upload uploads the local file as object with the given name
+func (s *S3) Upload(ctx context.Context, name string, file string, contentType string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	key := objectName(s.prefix, name)
+	s.logger.Event("storage-upload", logging.Fields{"file": file, "url": fmt.Sprintf("s3://%s/%s", s.bucket, key)},
+		"Uploading %s to s3://%s/%s", file, s.bucket, key)
+	if _, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        f,
+		ContentType: aws.String(contentType),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to upload %s to s3://%s/%s", file, s.bucket, key)
+	}
+	return nil
+}
+
+// BaseURL returns the URL under which the uploaded objects are served
+func (s *S3) BaseURL() string {
+	return s.baseURL
+}
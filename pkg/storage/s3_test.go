@@ -0,0 +1,120 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+type MockS3 struct {
+	s3iface.S3API
+	inputs []*s3.PutObjectInput
+	bodies []string
+}
+
+func (m *MockS3) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	body, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.inputs = append(m.inputs, input)
+	m.bodies = append(m.bodies, string(body))
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestS3_Upload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "index.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("apiVersion: v1\n"), 0644))
+
+	tests := []struct {
+		name   string
+		prefix string
+		key    string
+	}{
+		{
+			"no-prefix",
+			"",
+			"index.yaml",
+		},
+		{
+			"prefix",
+			"charts/",
+			"charts/index.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger, err := logging.New(logging.FormatJSON, &out)
+			assert.NoError(t, err)
+			s, err := NewS3("bucket", tt.prefix, "eu-west-1", "", logger)
+			assert.NoError(t, err)
+			client := &MockS3{}
+			s.client = client
+			err = s.Upload(context.Background(), "index.yaml", file, ContentTypeIndex)
+			assert.NoError(t, err)
+			assert.Len(t, client.inputs, 1)
+			assert.Equal(t, "bucket", aws.StringValue(client.inputs[0].Bucket))
+			assert.Equal(t, tt.key, aws.StringValue(client.inputs[0].Key))
+			assert.Equal(t, ContentTypeIndex, aws.StringValue(client.inputs[0].ContentType))
+			assert.Equal(t, "apiVersion: v1\n", client.bodies[0])
+
+			// uploads are logged in the format of the logger
+			var event map[string]interface{}
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &event))
+			assert.Equal(t, "storage-upload", event["action"])
+			assert.Equal(t, "s3://bucket/"+tt.key, event["url"])
+		})
+	}
+}
+
+func TestNewS3_BaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		want    string
+	}{
+		{
+			"bucket-url",
+			"",
+			"https://bucket.s3.eu-west-1.amazonaws.com/charts",
+		},
+		{
+			"custom-url",
+			"https://charts.example.com/",
+			"https://charts.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewS3("bucket", "charts", "eu-west-1", tt.baseURL, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, s.BaseURL())
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/helm/chart-releaser/pkg/logging"
+)
+
+const (
+	// ContentTypeIndex is the content type of index files
+	ContentTypeIndex = "text/yaml"
+	// ContentTypePackage is the content type of chart packages
+	ContentTypePackage = "application/gzip"
+	// ContentTypeProvenance is the content type of provenance files
+	ContentTypeProvenance = "application/pgp-signature"
+	// ContentTypeChecksum is the content type of the checksum of the index
+	ContentTypeChecksum = "text/plain"
+	// ContentTypeManifest is the content type of the manifest of the index
+	ContentTypeManifest = "application/json"
+	// ContentTypeHTML is the content type of the landing page of the index
+	ContentTypeHTML = "text/html; charset=utf-8"
+	// ContentTypeCompressedIndex is the content type of the gzipped index
+	ContentTypeCompressedIndex = "application/gzip"
+)
+
+// Backend is a storage location the packages and the index of a chart
+// repository are published to instead of GitHub Releases and GitHub Pages.
+type Backend interface {
+	// Upload uploads the local file as object with the given name.
+	Upload(ctx context.Context, name string, file string, contentType string) error
+	// BaseURL returns the URL under which the uploaded objects are served.
+	BaseURL() string
+}
+
+// Open returns the backend for a storage URL of the form s3://<bucket>/<prefix>
+// or gcs://<bucket>/<prefix>. The region is used for S3 buckets, and the
+// objects are served from the bucket URL. The uploads are logged with logger.
+func Open(storageURL string, region string, logger *logging.Logger) (Backend, error) {
+	u, err := url.Parse(storageURL)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid storage URL %q, must be s3://<bucket>/<prefix> or gcs://<bucket>/<prefix>", storageURL)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		return NewS3(u.Host, prefix, region, "", logger)
+	case "gcs", "gs":
+		return NewGCS(u.Host, prefix, "", logger)
+	default:
+		return nil, errors.Errorf("invalid storage URL %q, must be s3://<bucket>/<prefix> or gcs://<bucket>/<prefix>", storageURL)
+	}
+}
+
+// objectName returns the name of the object below the given prefix.
+func objectName(prefix string, name string) string {
+	return strings.TrimPrefix(path.Join(prefix, name), "/")
+}
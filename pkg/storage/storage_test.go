@@ -0,0 +1,36 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	b, err := Open("s3://my-charts/stable/", "eu-west-1", nil)
+	assert.NoError(t, err)
+	s3, ok := b.(*S3)
+	assert.True(t, ok)
+	assert.Equal(t, "my-charts", s3.bucket)
+	assert.Equal(t, "stable", s3.prefix)
+	assert.Equal(t, "https://my-charts.s3.eu-west-1.amazonaws.com/stable", s3.BaseURL())
+
+	for _, storageURL := range []string{"my-charts", "s3:///stable", "azure://my-charts/stable", "://"} {
+		_, err := Open(storageURL, "", nil)
+		assert.Error(t, err, storageURL)
+	}
+}
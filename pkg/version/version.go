@@ -0,0 +1,60 @@
+// Copyright The Helm Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package version provides the build metadata of chart-releaser, which the
+// Goreleaser build sets with ldflags, e.g.
+// -X github.com/helm/chart-releaser/pkg/version.version=v1.2.0.
+package version
+
+import "fmt"
+
+// DevVersion is the version of builds without build metadata, e.g. with
+// go build or when used as a library.
+const DevVersion = "unreleased"
+
+var (
+	// version is updated with the latest tag by the Goreleaser build
+	version = DevVersion
+	// gitCommit is updated with the Git commit by the Goreleaser build
+	gitCommit = "unknown"
+	// buildDate is updated with the current ISO timestamp by the Goreleaser build
+	buildDate = "unknown"
+)
+
+// Info is the build metadata of chart-releaser.
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+}
+
+// Version returns the version of chart-releaser.
+func Version() string {
+	return version
+}
+
+// BuildInfo returns the build metadata of chart-releaser.
+func BuildInfo() Info {
+	return Info{
+		Version:   version,
+		GitCommit: gitCommit,
+		BuildDate: buildDate,
+	}
+}
+
+// UserAgent returns the User-Agent chart-releaser sends by default, e.g.
+// chart-releaser/v1.2.0.
+func UserAgent() string {
+	return fmt.Sprintf("chart-releaser/%s", version)
+}